@@ -0,0 +1,21 @@
+package alert
+
+import (
+	"os"
+
+	logging "github.com/softcorp-io/hqs-privileges-service/logging"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewLogger - returns a logger for security alerts. It writes every entry to
+// stderr regardless of the log level used by the rest of the service, so
+// alerts cannot be silenced. Secrets are redacted as in every other log.
+func NewLogger() *zap.Logger {
+	core := zapcore.NewCore(
+		zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+		zapcore.Lock(os.Stderr),
+		zapcore.DebugLevel,
+	)
+	return zap.New(logging.Redact(core)).With(zap.Bool("alert", true))
+}
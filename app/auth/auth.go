@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Authentication methods an identity can come from.
+const (
+	MethodUserService = "user_service"
+	MethodJWT         = "jwt"
+	MethodStatic      = "static"
+	MethodBreakGlass  = "break_glass"
+)
+
+// Identity - who made a request and what they may do.
+type Identity struct {
+	UserID           string `json:"user_id"`
+	ManagePrivileges bool   `json:"manage_privileges"`
+	Root             bool   `json:"root"`
+	Method           string `json:"method"`
+}
+
+// Authenticator - turns the token of a request into the identity of the
+// caller. An error with code Unavailable means the authenticator could not
+// decide, any other error that the token is rejected.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Identity, error)
+}
+
+// ErrUnsupportedToken - returned by authenticators that do not understand the
+// format of a token, so another one may be tried.
+var ErrUnsupportedToken = status.Error(codes.Unauthenticated, "Token format not supported")
+
+// unavailable - reports whether err means the authenticator could not reach
+// what it needs to decide, as opposed to rejecting the token.
+func unavailable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal:
+		return true
+	}
+	return false
+}
+
+// Chain - tries each authenticator in turn, moving on only when one could not
+// decide or does not understand the token.
+type Chain []Authenticator
+
+// Authenticate - returns the identity from the first authenticator deciding.
+func (c Chain) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	err := ErrUnsupportedToken
+	for _, authenticator := range c {
+		var identity *Identity
+		identity, err = authenticator.Authenticate(ctx, token)
+		if err == nil {
+			return identity, nil
+		}
+		if err != ErrUnsupportedToken && !unavailable(err) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
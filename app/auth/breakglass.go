@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	alert "github.com/softcorp-io/hqs-privileges-service/alert"
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// BreakGlassStore - redeems break glass credentials.
+type BreakGlassStore interface {
+	UseBreakGlass(ctx context.Context, token string) (*repository.BreakGlass, error)
+}
+
+// BreakGlass - grants root access for break glass credentials and passes
+// every other token on to next. Every attempt to use a credential raises an
+// alert. Credentials are never cached, as every use is counted.
+type BreakGlass struct {
+	next     Authenticator
+	store    BreakGlassStore
+	alertLog *zap.Logger
+}
+
+// NewBreakGlass - returns next with break glass credentials redeemed by store.
+func NewBreakGlass(next Authenticator, store BreakGlassStore) *BreakGlass {
+	return &BreakGlass{next, store, alert.NewLogger()}
+}
+
+// Authenticate - redeems token if it is a break glass credential.
+func (b *BreakGlass) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	if !strings.HasPrefix(token, repository.BreakGlassPrefix) {
+		return b.next.Authenticate(ctx, token)
+	}
+
+	method, _ := grpc.Method(ctx)
+
+	glass, err := b.store.UseBreakGlass(ctx, token)
+	if err != nil {
+		b.alertLog.Error("Rejected break glass credential", zap.String("method", method), zap.Error(err))
+		return nil, err
+	}
+
+	b.alertLog.Warn("Break glass credential used for root access",
+		zap.String("method", method),
+		zap.String("break_glass_id", glass.ID),
+		zap.String("reason", glass.Reason),
+		zap.Int("uses", glass.Uses),
+		zap.Int("max_uses", glass.MaxUses),
+		zap.Time("expires_at", glass.ExpiresAt),
+	)
+	return &Identity{
+		UserID:           "breakglass:" + glass.ID,
+		ManagePrivileges: true,
+		Root:             true,
+		Method:           MethodBreakGlass,
+	}, nil
+}
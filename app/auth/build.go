@@ -0,0 +1,29 @@
+package auth
+
+import (
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+)
+
+// FromConfig - chains the authenticators named by the configured auth modes.
+func FromConfig(cfg config.Auth, users TokenValidator) (Authenticator, error) {
+	chain := Chain{}
+	for _, mode := range cfg.Modes {
+		switch mode {
+		case config.AuthUserService:
+			chain = append(chain, NewUserService(users))
+		case config.AuthJWT:
+			jwt, err := NewJWT(cfg.JWT)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, jwt)
+		case config.AuthStatic:
+			chain = append(chain, NewStatic(cfg.StaticToken, cfg.StaticUser))
+		}
+	}
+
+	if len(chain) == 1 {
+		return chain[0], nil
+	}
+	return chain, nil
+}
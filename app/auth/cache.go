@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+)
+
+// Cache - bounded cache of authentications in front of another
+// Authenticator. Tokens are keyed by their sha256 hash so raw tokens are never
+// kept. Rejected tokens are cached for a shorter time, failures to decide are
+// not cached at all.
+type Cache struct {
+	next        Authenticator
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu         sync.Mutex
+	entries    map[[sha256.Size]byte]*list.Element
+	order      *list.List
+	generation int64
+	hits       int64
+	misses     int64
+}
+
+type cacheEntry struct {
+	key      [sha256.Size]byte
+	identity *Identity
+	err      error
+	expires  time.Time
+}
+
+// NewCache - returns a cache in front of next. A size of 0 disables caching.
+func NewCache(next Authenticator, cfg config.TokenCache) *Cache {
+	return &Cache{
+		next:        next,
+		size:        int(cfg.Size),
+		ttl:         time.Duration(cfg.TTL),
+		negativeTTL: time.Duration(cfg.NegativeTTL),
+		entries:     map[[sha256.Size]byte]*list.Element{},
+		order:       list.New(),
+	}
+}
+
+// Authenticate - returns the cached identity of token, asking next when
+// there is none.
+func (c *Cache) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	if c.size <= 0 {
+		return c.next.Authenticate(ctx, token)
+	}
+
+	key := sha256.Sum256([]byte(token))
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		if time.Now().Before(entry.expires) {
+			c.order.MoveToFront(element)
+			c.hits++
+			c.mu.Unlock()
+			return entry.identity, entry.err
+		}
+		c.remove(element)
+	}
+	c.misses++
+	generation := c.generation
+	c.mu.Unlock()
+
+	result, err := c.next.Authenticate(ctx, token)
+
+	ttl := c.ttl
+	if err != nil {
+		if unavailable(err) || ctx.Err() != nil {
+			return result, err
+		}
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 {
+		return result, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// a change while next was asked may have made result stale
+	if generation != c.generation {
+		return result, err
+	}
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key, result, err, time.Now().Add(ttl)})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+
+	return result, err
+}
+
+// Invalidate - drops every cached identity, so changes to privileges take
+// effect on the next request.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = map[[sha256.Size]byte]*list.Element{}
+	c.order.Init()
+	c.generation++
+}
+
+// CacheStats - hits, misses and size of the cache.
+type CacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+}
+
+// Stats - a snapshot of the cache statistics.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{c.hits, c.misses, c.order.Len()}
+}
+
+func (c *Cache) remove(element *list.Element) {
+	delete(c.entries, element.Value.(*cacheEntry).key)
+	c.order.Remove(element)
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// countingAuthenticator - answers with err, or an identity named after the
+// token. It counts calls and runs during, if set, before answering.
+type countingAuthenticator struct {
+	err    error
+	during func()
+	calls  int
+}
+
+func (a *countingAuthenticator) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	a.calls++
+	if a.during != nil {
+		a.during()
+	}
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &Identity{UserID: token}, nil
+}
+
+func TestCacheAuthenticate(t *testing.T) {
+	rejected := status.Error(codes.Unauthenticated, "rejected")
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	tests := []struct {
+		name        string
+		size        int64
+		negativeTTL time.Duration
+		err         error
+		// invalidate while the first token is authenticated
+		invalidateDuring bool
+		// invalidate between the two rounds
+		invalidateBetween bool
+		tokens            []string
+		calls             int
+	}{
+		{"hit", 10, time.Minute, nil, false, false, []string{"a"}, 1},
+		{"invalidated while authenticating", 10, time.Minute, nil, true, false, []string{"a"}, 2},
+		{"invalidated between calls", 10, time.Minute, nil, false, true, []string{"a"}, 2},
+		{"rejected tokens are cached", 10, time.Minute, rejected, false, false, []string{"a"}, 1},
+		{"rejected tokens without negative ttl", 10, 0, rejected, false, false, []string{"a"}, 2},
+		{"failures are not cached", 10, time.Minute, unavailable, false, false, []string{"a"}, 2},
+		{"least recently used is evicted", 1, time.Minute, nil, false, false, []string{"a", "b"}, 4},
+		{"disabled", 0, time.Minute, nil, false, false, []string{"a"}, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			next := &countingAuthenticator{err: test.err}
+			cache := NewCache(next, config.TokenCache{
+				Size:        test.size,
+				TTL:         config.Duration(time.Minute),
+				NegativeTTL: config.Duration(test.negativeTTL),
+			})
+			if test.invalidateDuring {
+				next.during = func() {
+					next.during = nil
+					cache.Invalidate()
+				}
+			}
+
+			for round := 0; round < 2; round++ {
+				if round == 1 && test.invalidateBetween {
+					cache.Invalidate()
+				}
+				for _, token := range test.tokens {
+					identity, err := cache.Authenticate(context.Background(), token)
+					if status.Code(err) != status.Code(test.err) {
+						t.Fatalf("expected %v, got %v", test.err, err)
+					}
+					if err == nil && identity.UserID != token {
+						t.Fatalf("expected identity of %s, got %+v", token, identity)
+					}
+				}
+			}
+
+			if next.calls != test.calls {
+				t.Fatalf("expected %d calls, got %d", test.calls, next.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package auth
+
+import (
+	"context"
+)
+
+type identityKey struct{}
+
+// NewContext - returns ctx carrying identity.
+func NewContext(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext - the identity of the caller, if the method required one.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok
+}
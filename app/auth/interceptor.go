@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	logging "github.com/softcorp-io/hqs-privileges-service/logging"
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Guard - authenticates and authorizes calls according to a policy.
+type Guard struct {
+	authenticator Authenticator
+	policy        Policy
+	zapLog        *zap.Logger
+}
+
+// NewGuard - returns a guard checking calls against policy.
+func NewGuard(authenticator Authenticator, policy Policy, zapLog *zap.Logger) *Guard {
+	return &Guard{authenticator, policy, zapLog}
+}
+
+// UnaryInterceptor - checks unary calls before the handler runs.
+func (g *Guard) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := g.check(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor - checks streaming calls before the handler runs.
+func (g *Guard) StreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := g.check(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &guardedStream{stream, ctx})
+}
+
+// check - returns ctx with the identity of the caller if it may call method.
+func (g *Guard) check(ctx context.Context, method string) (context.Context, error) {
+	requirement := g.policy.Requirement(method)
+	switch requirement {
+	case Public:
+		return ctx, nil
+	case Deny:
+		logging.FromContext(ctx, g.zapLog).Warn(fmt.Sprintf("Denied call to %s, it is not in the method policy", method))
+		return ctx, status.Error(codes.PermissionDenied, "Method is not allowed")
+	}
+
+	token, err := tokenFromContext(ctx)
+	if err != nil {
+		logging.FromContext(ctx, g.zapLog).Error(fmt.Sprintf("Could not validate token with err %v", err))
+		return ctx, err
+	}
+
+	identity, err := g.authenticator.Authenticate(ctx, token)
+	if err != nil {
+		logging.FromContext(ctx, g.zapLog).Error(fmt.Sprintf("Could not authenticate token with err %v", err))
+		if unavailable(err) {
+			return ctx, err
+		}
+		if _, ok := status.FromError(err); !ok {
+			err = status.Error(codes.Unauthenticated, err.Error())
+		}
+		return ctx, err
+	}
+	logging.Annotate(ctx, zap.String("caller_id", identity.UserID), zap.String("auth_method", identity.Method))
+	if !allows(requirement, identity) {
+		return ctx, status.Error(codes.PermissionDenied, fmt.Sprintf("User not allowed to call %s, requires %s", method, requirement))
+	}
+
+	ctx = NewContext(ctx, identity)
+	return repository.WithActor(ctx, identity.UserID), nil
+}
+
+// tokenFromContext - the token of the caller, from the token header or a
+// bearer authorization header.
+func tokenFromContext(ctx context.Context) (string, error) {
+	meta, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "Could not validate token")
+	}
+
+	token := meta.Get("token")
+	if len(token) == 0 {
+		for _, authorization := range meta.Get("authorization") {
+			if strings.HasPrefix(authorization, "Bearer ") {
+				token = append(token, strings.TrimPrefix(authorization, "Bearer "))
+			}
+		}
+	}
+	if len(token) == 0 {
+		return "", status.Error(codes.Unauthenticated, "Missing token header in context")
+	}
+	if strings.Trim(token[0], " ") == "" {
+		return "", status.Error(codes.Unauthenticated, "Token is empty")
+	}
+
+	return token[0], nil
+}
+
+// guardedStream - a server stream carrying the context of the guard.
+type guardedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *guardedStream) Context() context.Context {
+	return s.ctx
+}
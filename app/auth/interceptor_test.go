@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGuardCheck(t *testing.T) {
+	policy := Policy{
+		"/svc/Ping":   Public,
+		"/svc/Get":    Authenticated,
+		"/svc/Create": ManagePrivileges,
+	}
+	user := &Identity{UserID: "user", Method: MethodJWT}
+	admin := &Identity{UserID: "admin", Method: MethodJWT, ManagePrivileges: true}
+	root := &Identity{UserID: "root", Method: MethodJWT, Root: true}
+
+	tests := []struct {
+		name     string
+		method   string
+		token    string
+		identity *Identity
+		code     codes.Code
+	}{
+		{"public without a token", "/svc/Ping", "", nil, codes.OK},
+		{"unknown method", "/svc/Unknown", "token", admin, codes.PermissionDenied},
+		{"unknown method of root", "/svc/Unknown", "token", root, codes.PermissionDenied},
+		{"unknown method without a token", "/svc/Unknown", "", nil, codes.PermissionDenied},
+		{"authenticated", "/svc/Get", "token", user, codes.OK},
+		{"authenticated without a token", "/svc/Get", "", nil, codes.Unauthenticated},
+		{"manage privileges without the permission", "/svc/Create", "token", user, codes.PermissionDenied},
+		{"manage privileges", "/svc/Create", "token", admin, codes.OK},
+		{"manage privileges as root", "/svc/Create", "token", root, codes.OK},
+		{"manage privileges without a token", "/svc/Create", "", nil, codes.Unauthenticated},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			authenticator := identityFunc(func() (*Identity, error) {
+				if test.identity == nil {
+					t.Fatal("expected the token not to be authenticated")
+				}
+				return test.identity, nil
+			})
+			g := NewGuard(authenticator, policy, zap.NewNop())
+
+			ctx := context.Background()
+			if test.token != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("token", test.token))
+			}
+			ctx, err := g.check(ctx, test.method)
+			if status.Code(err) != test.code {
+				t.Fatalf("expected %v, got %v", test.code, err)
+			}
+			if err != nil || test.identity == nil {
+				return
+			}
+			identity, ok := FromContext(ctx)
+			if !ok || identity.UserID != test.identity.UserID {
+				t.Fatalf("expected identity %+v in context, got %+v", test.identity, identity)
+			}
+		})
+	}
+}
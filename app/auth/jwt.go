@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// leeway - clock skew allowed when checking exp and nbf.
+const leeway = 30 * time.Second
+
+// JWT - authenticates tokens by verifying them as json web tokens signed with
+// one of the configured keys, without calling the user service.
+type JWT struct {
+	keys     *keySet
+	issuer   string
+	audience string
+}
+
+// NewJWT - returns a jwt authenticator with the keys in cfg.
+func NewJWT(cfg config.JWT) (*JWT, error) {
+	keys := &keySet{
+		url:     cfg.JWKSURL,
+		refresh: time.Duration(cfg.JWKSRefresh),
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+
+	for _, path := range cfg.KeyFiles {
+		loaded, err := loadPEM(path)
+		if err != nil {
+			return nil, err
+		}
+		keys.static = append(keys.static, loaded...)
+	}
+	if cfg.HMACSecret != "" {
+		keys.static = append(keys.static, &key{secret: []byte(cfg.HMACSecret)})
+	}
+	if cfg.JWKSFile != "" {
+		data, err := ioutil.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		loaded, err := parseJWKS(data, true)
+		if err != nil {
+			return nil, err
+		}
+		keys.static = append(keys.static, loaded...)
+	}
+
+	return &JWT{keys, cfg.Issuer, cfg.Audience}, nil
+}
+
+// algorithms - the signing algorithms accepted, by name.
+var algorithms = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// Authenticate - verifies token and returns the user it names as subject.
+// What the user may do is left to Permissions.
+func (j *JWT) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrUnsupportedToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrUnsupportedToken
+	}
+	hash, ok := algorithms[header.Alg]
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Token algorithm not accepted")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "Token signature is malformed")
+	}
+
+	keys, err := j.keys.keys(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, k := range keys {
+		if header.Kid != "" && k.id != "" && k.id != header.Kid {
+			continue
+		}
+		if verify(header.Alg, hash, k, signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, status.Error(codes.Unauthenticated, "Token signature is not valid")
+	}
+
+	claims := map[string]interface{}{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, status.Error(codes.Unauthenticated, "Token claims are malformed")
+	}
+	if err := j.checkClaims(claims); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	subject, _ := claims["sub"].(string)
+	return &Identity{UserID: subject, Method: MethodJWT}, nil
+}
+
+// checkClaims - checks expiry, not before, issuer and audience.
+func (j *JWT) checkClaims(claims map[string]interface{}) error {
+	now := time.Now()
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("Token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(leeway)) {
+		return errors.New("Token has expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(leeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("Token is not valid yet")
+	}
+	if subject, _ := claims["sub"].(string); subject == "" {
+		return errors.New("Token has no subject")
+	}
+
+	if j.issuer != "" {
+		if issuer, _ := claims["iss"].(string); issuer != j.issuer {
+			return errors.New("Token issuer not accepted")
+		}
+	}
+	if j.audience != "" && !hasAudience(claims["aud"], j.audience) {
+		return errors.New("Token audience not accepted")
+	}
+
+	return nil
+}
+
+func hasAudience(claim interface{}, audience string) bool {
+	switch aud := claim.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verify - reports whether signature over signed was made by k with alg.
+func verify(alg string, hash crypto.Hash, k *key, signed []byte, signature []byte) bool {
+	hasher := hash.New()
+	hasher.Write(signed)
+	digest := hasher.Sum(nil)
+
+	switch alg[:2] {
+	case "HS":
+		if k.secret == nil {
+			return false
+		}
+		mac := hmac.New(hash.New, k.secret)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case "RS":
+		public, ok := k.public.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(public, hash, digest, signature) == nil
+	case "PS":
+		public, ok := k.public.(*rsa.PublicKey)
+		return ok && rsa.VerifyPSS(public, hash, digest, signature, nil) == nil
+	case "ES":
+		public, ok := k.public.(*ecdsa.PublicKey)
+		if !ok {
+			return false
+		}
+		size := (public.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(public, digest, r, s)
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// signToken - a token with alg and kid in its header and claims, signed by
+// sign.
+func signToken(t *testing.T, alg string, kid string, claims map[string]interface{}, sign func(signed []byte) []byte) string {
+	t.Helper()
+
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	signed := encode(header) + "." + encode(claims)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+func hmacSigner(secret []byte) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		mac := hmac.New(crypto.SHA256.New, secret)
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+}
+
+func rsaSigner(t *testing.T, private *rsa.PrivateKey) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		digest := crypto.SHA256.New()
+		digest.Write(signed)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, private, crypto.SHA256, digest.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+}
+
+func ecdsaSigner(t *testing.T, private *ecdsa.PrivateKey) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		digest := crypto.SHA256.New()
+		digest.Write(signed)
+		r, s, err := ecdsa.Sign(rand.Reader, private, digest.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature
+	}
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	secretA := []byte("secret-a")
+	secretB := []byte("secret-b")
+	valid := map[string]interface{}{"sub": "user", "exp": float64(time.Now().Add(time.Hour).Unix())}
+	expired := map[string]interface{}{"sub": "user", "exp": float64(time.Now().Add(-time.Hour).Unix())}
+
+	rsaOnly := []*key{{public: &rsaKey.PublicKey}}
+	withKids := []*key{{id: "a", secret: secretA}, {id: "b", secret: secretB}}
+
+	tests := []struct {
+		name  string
+		keys  []*key
+		token string
+		ok    bool
+	}{
+		{"hmac", []*key{{secret: secretA}}, signToken(t, "HS256", "", valid, hmacSigner(secretA)), true},
+		{"rsa", rsaOnly, signToken(t, "RS256", "", valid, rsaSigner(t, rsaKey)), true},
+		{"ecdsa", []*key{{public: &ecKey.PublicKey}}, signToken(t, "ES256", "", valid, ecdsaSigner(t, ecKey)), true},
+		{"hmac signed with the rsa public key", rsaOnly, signToken(t, "HS256", "", valid, hmacSigner(rsaPEM)), false},
+		{"hmac signed with the rsa public key der", rsaOnly, signToken(t, "HS256", "", valid, hmacSigner(der)), false},
+		{"alg none", []*key{{secret: secretA}}, signToken(t, "none", "", valid, func([]byte) []byte { return nil }), false},
+		{"ecdsa alg with rsa key", rsaOnly, signToken(t, "ES256", "", valid, ecdsaSigner(t, ecKey)), false},
+		{"rsa alg with hmac key", []*key{{secret: secretA}}, signToken(t, "RS256", "", valid, hmacSigner(secretA)), false},
+		{"kid picks its key", withKids, signToken(t, "HS256", "b", valid, hmacSigner(secretB)), true},
+		{"kid of another key", withKids, signToken(t, "HS256", "a", valid, hmacSigner(secretB)), false},
+		{"unknown kid", withKids, signToken(t, "HS256", "c", valid, hmacSigner(secretA)), false},
+		{"no kid tries every key", withKids, signToken(t, "HS256", "", valid, hmacSigner(secretB)), true},
+		{"kid with keys without ids", []*key{{secret: secretA}}, signToken(t, "HS256", "a", valid, hmacSigner(secretA)), true},
+		{"wrong secret", []*key{{secret: secretA}}, signToken(t, "HS256", "", valid, hmacSigner(secretB)), false},
+		{"expired", []*key{{secret: secretA}}, signToken(t, "HS256", "", expired, hmacSigner(secretA)), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			j := &JWT{keys: &keySet{static: test.keys}}
+			identity, err := j.Authenticate(context.Background(), test.token)
+			if !test.ok {
+				if err == nil {
+					t.Fatalf("expected token to be refused, got %+v", identity)
+				}
+				if status.Code(err) != codes.Unauthenticated {
+					t.Fatalf("expected Unauthenticated, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected token to be accepted, got %v", err)
+			}
+			if identity.UserID != "user" || identity.Method != MethodJWT {
+				t.Fatalf("unexpected identity %+v", identity)
+			}
+		})
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+	ec := map[string]string{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X), "y": encode(ecKey.Y)}
+	oct := map[string]string{"kty": "oct", "kid": "oct", "k": base64.RawURLEncoding.EncodeToString([]byte("secret"))}
+	enc := map[string]string{"kty": "oct", "kid": "enc", "use": "enc", "k": base64.RawURLEncoding.EncodeToString([]byte("secret"))}
+
+	tests := []struct {
+		name         string
+		keys         []map[string]string
+		allowSecrets bool
+		ids          []string
+		fails        bool
+	}{
+		{"public keys", []map[string]string{ec}, false, []string{"ec"}, false},
+		{"secret from a file", []map[string]string{ec, oct}, true, []string{"ec", "oct"}, false},
+		{"secret from a url", []map[string]string{ec, oct}, false, nil, true},
+		{"encryption keys are skipped", []map[string]string{enc}, false, []string{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := json.Marshal(map[string]interface{}{"keys": test.keys})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			keys, err := parseJWKS(data, test.allowSecrets)
+			if test.fails {
+				if err == nil {
+					t.Fatal("expected jwks to be refused")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != len(test.ids) {
+				t.Fatalf("expected %d keys, got %d", len(test.ids), len(keys))
+			}
+			for i, k := range keys {
+				if k.id != test.ids[i] {
+					t.Fatalf("expected key %s, got %s", test.ids[i], k.id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// key - a key jwts may be signed with. Exactly one of public and secret is
+// set.
+type key struct {
+	id     string
+	public interface{}
+	secret []byte
+}
+
+// keySet - the keys of a jwt authenticator. Keys from a jwks url are fetched
+// again every refresh, or sooner when a token names an unknown key.
+type keySet struct {
+	static  []*key
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu        sync.Mutex
+	fetched   []*key
+	fetchedAt time.Time
+	triedAt   time.Time
+	fetchErr  error
+	fetching  chan struct{}
+}
+
+// minRefetch - shortest time between two fetches of the jwks url for tokens
+// naming an unknown key.
+const minRefetch = 30 * time.Second
+
+// keys - every key, fetching the jwks url in the background when it is due.
+// Only one fetch runs at a time and the keys fetched last are used meanwhile,
+// callers wait for it only when those keys are unusable or lack kid. When
+// fetching fails the keys fetched last are used until they are older than
+// twice the refresh.
+func (s *keySet) keys(ctx context.Context, kid string) ([]*key, error) {
+	if s.url == "" {
+		return s.static, nil
+	}
+
+	s.mu.Lock()
+	age := time.Since(s.fetchedAt)
+	unknown := kid != "" && !hasKey(s.fetched, kid)
+	due := age >= s.refresh || (unknown && time.Since(s.triedAt) >= minRefetch)
+	if due && s.fetching == nil {
+		s.fetching = make(chan struct{})
+		s.triedAt = time.Now()
+		go s.refetch(s.fetching)
+	}
+	fetching := s.fetching
+	usable := !s.fetchedAt.IsZero() && age < 2*s.refresh
+	s.mu.Unlock()
+
+	if fetching != nil && (!usable || unknown) {
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fetchedAt.IsZero() || time.Since(s.fetchedAt) >= 2*s.refresh {
+		return nil, status.Error(codes.Unavailable, fmt.Sprintf("Could not fetch jwks with err %v", s.fetchErr))
+	}
+
+	return append(append([]*key{}, s.static...), s.fetched...), nil
+}
+
+// refetch - fetches the jwks url and closes done once the keys are updated.
+// It does not use the context of the caller that started it, as other callers
+// may wait for it too; the client timeout bounds it.
+func (s *keySet) refetch(done chan struct{}) {
+	fetched, err := s.fetch(context.Background())
+
+	s.mu.Lock()
+	if err == nil {
+		s.fetched, s.fetchedAt = fetched, time.Now()
+	}
+	s.fetchErr = err
+	s.fetching = nil
+	s.mu.Unlock()
+
+	close(done)
+}
+
+func (s *keySet) fetch(ctx context.Context) ([]*key, error) {
+	req, err := http.NewRequest(http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks url answered %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// a symmetric key served over the network is no secret, anyone able to
+	// read it could sign tokens
+	return parseJWKS(data, false)
+}
+
+func hasKey(keys []*key, kid string) bool {
+	for _, k := range keys {
+		if k.id == kid {
+			return true
+		}
+	}
+	return false
+}
+
+// loadPEM - reads the public keys or certificates in a pem file.
+func loadPEM(path string) ([]*key, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []*key{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		var public interface{}
+		switch block.Type {
+		case "PUBLIC KEY":
+			public, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			cert, err = x509.ParseCertificate(block.Bytes)
+			if err == nil {
+				public = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		keys = append(keys, &key{public: public})
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no public keys found", path)
+	}
+	return keys, nil
+}
+
+// jwk - a single json web key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// parseJWKS - reads the signing keys of a json web key set. Keys of unknown
+// types are skipped, symmetric keys are refused unless allowSecrets is set.
+func parseJWKS(data []byte, allowSecrets bool) ([]*key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := []*key{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if k.Kty == "oct" && !allowSecrets {
+			return nil, fmt.Errorf("key %s: symmetric keys are only accepted from AUTH_JWT_HMAC_SECRET or AUTH_JWT_JWKS_FILE", k.Kid)
+		}
+
+		parsed, err := k.parse()
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %v", k.Kid, err)
+		}
+		if parsed != nil {
+			keys = append(keys, parsed)
+		}
+	}
+	return keys, nil
+}
+
+func (k jwk) parse() (*key, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &key{id: k.Kid, public: &rsa.PublicKey{N: n, E: int(e.Int64())}}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unknown curve %s", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return &key{id: k.Kid, public: &ecdsa.PublicKey{Curve: curve, X: x, Y: y}}, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, err
+		}
+		return &key{id: k.Kid, secret: secret}, nil
+	}
+	return nil, nil
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestKeySetKeys(t *testing.T) {
+	oct := `{"keys":[{"kty":"oct","kid":"oct","k":"` + base64.RawURLEncoding.EncodeToString([]byte("secret")) + `"}]}`
+	rsa := `{"keys":[{"kty":"RSA","kid":"%s","n":"AQAB","e":"AQAB"}]}`
+	cached := []*key{{id: "cached"}}
+
+	tests := []struct {
+		name string
+		body string
+		// age of the cached keys in refreshes, none when negative
+		age  time.Duration
+		kid  string
+		slow bool
+		ids  []string
+		code codes.Code
+	}{
+		{"first fetch", fmt.Sprintf(rsa, "new"), -1, "", false, []string{"new"}, codes.OK},
+		{"fresh keys are not fetched", fmt.Sprintf(rsa, "new"), 0, "", false, []string{"cached"}, codes.OK},
+		{"stale keys while fetching", fmt.Sprintf(rsa, "new"), 1, "", true, []string{"cached"}, codes.OK},
+		{"unknown kid waits for the fetch", fmt.Sprintf(rsa, "new"), 1, "new", false, []string{"new"}, codes.OK},
+		{"expired keys wait for the fetch", fmt.Sprintf(rsa, "new"), 2, "", false, []string{"new"}, codes.OK},
+		{"symmetric keys from a url", oct, -1, "", false, nil, codes.Unavailable},
+		{"symmetric keys keep stale keys", oct, 1, "oct", false, []string{"cached"}, codes.OK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			release := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.slow {
+					<-release
+				}
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+			defer close(release)
+
+			refresh := time.Minute
+			s := &keySet{url: server.URL, refresh: refresh, client: server.Client()}
+			if test.age >= 0 {
+				s.fetched = cached
+				s.fetchedAt = time.Now().Add(-test.age*refresh - time.Second)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			keys, err := s.keys(ctx, test.kid)
+			if status.Code(err) != test.code {
+				t.Fatalf("expected %v, got %v", test.code, err)
+			}
+			if len(keys) != len(test.ids) {
+				t.Fatalf("expected %d keys, got %d", len(test.ids), len(keys))
+			}
+			for i, k := range keys {
+				if k.id != test.ids[i] {
+					t.Fatalf("expected key %s, got %s", test.ids[i], k.id)
+				}
+			}
+		})
+	}
+}
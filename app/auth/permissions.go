@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PermissionResolver - works out what a user may do from every privilege it
+// holds, directly and through groups.
+type PermissionResolver interface {
+	GetEffectivePermissions(ctx context.Context, userID string) (*repository.EffectivePermissions, error)
+}
+
+// Permissions - leaves who the caller is to next and takes what the caller may
+// do from the stored privileges, the same way the lockout guard counts
+// administrators. Static and break glass identities are taken as they are.
+type Permissions struct {
+	next     Authenticator
+	resolver PermissionResolver
+}
+
+// NewPermissions - returns next with permissions resolved by resolver.
+func NewPermissions(next Authenticator, resolver PermissionResolver) *Permissions {
+	return &Permissions{next, resolver}
+}
+
+// Authenticate - returns the identity next gives token with its effective
+// permissions. Identities without a user are refused, users unknown to the
+// repository hold no permissions.
+func (p *Permissions) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	identity, err := p.next.Authenticate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if identity.Method == MethodStatic || identity.Method == MethodBreakGlass {
+		return identity, nil
+	}
+
+	if identity.UserID == "" {
+		return nil, status.Error(codes.Unauthenticated, "Token does not belong to a user")
+	}
+
+	resolved := &Identity{UserID: identity.UserID, Method: identity.Method}
+	effective, err := p.resolver.GetEffectivePermissions(ctx, identity.UserID)
+	if repository.IsNotFound(err) {
+		return resolved, nil
+	}
+	if err != nil {
+		return nil, resolveError(err)
+	}
+
+	resolved.ManagePrivileges = effective.Privilege.ManagePrivileges
+	resolved.Root = effective.Privilege.Root
+	return resolved, nil
+}
+
+// resolveError - the status error for a failure resolving permissions, with
+// the code the handlers would give it. Failing to reach the database fails
+// closed, but lets the caller retry.
+func resolveError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	repoErr := repository.AsError(err)
+	if repoErr == nil {
+		// anything else the database could not do
+		return status.Error(codes.Unavailable, "Could not resolve permissions")
+	}
+	switch repoErr.Kind {
+	case repository.KindValidation:
+		return status.Error(codes.InvalidArgument, repoErr.Error())
+	case repository.KindConflict, repository.KindProtected:
+		return status.Error(codes.FailedPrecondition, repoErr.Error())
+	case repository.KindPermissionDenied:
+		return status.Error(codes.PermissionDenied, repoErr.Error())
+	case repository.KindUnavailable:
+		return status.Error(codes.Unavailable, "Could not resolve permissions")
+	}
+	return status.Error(codes.Internal, "Could not resolve permissions")
+}
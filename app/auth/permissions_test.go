@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type identityFunc func() (*Identity, error)
+
+func (f identityFunc) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	return f()
+}
+
+type resolverFunc func() (*repository.EffectivePermissions, error)
+
+func (f resolverFunc) GetEffectivePermissions(ctx context.Context, userID string) (*repository.EffectivePermissions, error) {
+	return f()
+}
+
+func TestPermissionsAuthenticate(t *testing.T) {
+	user := &Identity{UserID: "user", Method: MethodJWT}
+	admin := &repository.EffectivePermissions{Privilege: &repository.Privilege{ManagePrivileges: true}}
+	failing := func(err error) resolverFunc {
+		return func() (*repository.EffectivePermissions, error) { return nil, err }
+	}
+
+	tests := []struct {
+		name     string
+		identity *Identity
+		resolver resolverFunc
+		code     codes.Code
+		manage   bool
+	}{
+		{"resolved", user, func() (*repository.EffectivePermissions, error) { return admin, nil }, codes.OK, true},
+		{"unknown user", user, failing(&repository.Error{Kind: repository.KindNotFound}), codes.OK, false},
+		{"static identity as it is", &Identity{Method: MethodStatic, ManagePrivileges: true}, failing(errors.New("not called")), codes.OK, true},
+		{"no user", &Identity{Method: MethodUserService}, func() (*repository.EffectivePermissions, error) { return admin, nil }, codes.Unauthenticated, false},
+		{"invalid user id", user, failing(&repository.Error{Kind: repository.KindValidation, Field: "id", Message: "ID is invalid"}), codes.InvalidArgument, false},
+		{"database unavailable", user, failing(&repository.Error{Kind: repository.KindUnavailable}), codes.Unavailable, false},
+		{"database failure", user, failing(errors.New("connection reset")), codes.Unavailable, false},
+		{"internal error", user, failing(&repository.Error{Kind: repository.KindInternal}), codes.Internal, false},
+		{"canceled", user, failing(context.Canceled), codes.Canceled, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			next := identityFunc(func() (*Identity, error) { return test.identity, nil })
+			identity, err := NewPermissions(next, test.resolver).Authenticate(context.Background(), "token")
+			if status.Code(err) != test.code {
+				t.Fatalf("expected %v, got %v", test.code, err)
+			}
+			if err != nil {
+				return
+			}
+			if identity.ManagePrivileges != test.manage {
+				t.Fatalf("expected manage privileges %v, got %v", test.manage, identity.ManagePrivileges)
+			}
+		})
+	}
+}
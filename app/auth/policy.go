@@ -0,0 +1,45 @@
+package auth
+
+// Requirement - what a caller needs to call a method.
+type Requirement int
+
+// Requirements, from least to most demanding. The zero value denies every
+// caller, so methods missing from a policy cannot be called.
+const (
+	Deny Requirement = iota
+	Public
+	Authenticated
+	ManagePrivileges
+)
+
+func (r Requirement) String() string {
+	switch r {
+	case Public:
+		return "public"
+	case Authenticated:
+		return "authenticated"
+	case ManagePrivileges:
+		return "manage_privileges"
+	}
+	return "deny"
+}
+
+// Policy - the requirement of every method by full grpc method name, such as
+// /hqs_privilege_service.PrivilegeService/Get.
+type Policy map[string]Requirement
+
+// Requirement - the requirement of method, Deny if it is not in the policy.
+func (p Policy) Requirement(method string) Requirement {
+	return p[method]
+}
+
+// allows - reports whether identity meets requirement.
+func allows(requirement Requirement, identity *Identity) bool {
+	switch requirement {
+	case Authenticated:
+		return true
+	case ManagePrivileges:
+		return identity.ManagePrivileges || identity.Root
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+
+	userProto "github.com/softcorp-io/hqs_proto/go_hqs/hqs_user_service"
+)
+
+// TokenValidator - validates tokens with the user service.
+type TokenValidator interface {
+	ValidateToken(ctx context.Context, token string) (*userProto.Token, error)
+}
+
+// UserService - authenticates tokens by asking the user service.
+type UserService struct {
+	users TokenValidator
+}
+
+// NewUserService - returns an authenticator asking users.
+func NewUserService(users TokenValidator) *UserService {
+	return &UserService{users}
+}
+
+// Authenticate - returns the user the user service says token belongs to.
+// The user service only knows a single privilege per user, so what the user
+// may do is left to Permissions.
+func (u *UserService) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	result, err := u.users.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{
+		UserID: result.Id,
+		Method: MethodUserService,
+	}, nil
+}
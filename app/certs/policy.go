@@ -0,0 +1,82 @@
+package certs
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// AnyService - stands for every grpc service in a SANPolicy.
+const AnyService = "*"
+
+// SANPolicy - the subject alternative names of the client certificates
+// allowed to call each grpc service.
+type SANPolicy map[string][]string
+
+// ParseSANPolicy - reads "service=san" entries into a policy.
+func ParseSANPolicy(entries []string) (SANPolicy, error) {
+	policy := SANPolicy{}
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.New("Allowed SAN " + entry + " must look like service=san")
+		}
+		policy[parts[0]] = append(policy[parts[0]], parts[1])
+	}
+	return policy, nil
+}
+
+// allows - reports whether a certificate with sans may call fullMethod.
+func (p SANPolicy) allows(fullMethod string, sans []string) bool {
+	service := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(service, "/"); i >= 0 {
+		service = service[:i]
+	}
+
+	allowed := append(append([]string{}, p[service]...), p[AnyService]...)
+	for _, san := range sans {
+		for _, a := range allowed {
+			if san == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// check - refuses calls whose client certificate is not allowed for the
+// service called.
+func (p SANPolicy) check(ctx context.Context, fullMethod string) error {
+	sans := []string{}
+	if pr, ok := peer.FromContext(ctx); ok {
+		if info, ok := pr.AuthInfo.(credentials.TLSInfo); ok && len(info.State.PeerCertificates) > 0 {
+			sans = SANs(info.State.PeerCertificates[0])
+		}
+	}
+	if !p.allows(fullMethod, sans) {
+		return status.Error(codes.PermissionDenied, "Client certificate is not allowed to call this service")
+	}
+	return nil
+}
+
+// UnaryInterceptor - enforces the policy on unary calls.
+func (p SANPolicy) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := p.check(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor - enforces the policy on streaming calls.
+func (p SANPolicy) StreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := p.check(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
@@ -0,0 +1,147 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Store - a certificate with its key and a CA bundle read from disk. The files
+// are read again on Reload and swapped in when they changed, so certificates
+// rotated on disk are used by new connections without a restart.
+type Store struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	zapLog   *zap.Logger
+
+	mu   sync.RWMutex
+	sum  []byte
+	cert *tls.Certificate
+	pool *x509.CertPool
+}
+
+// NewStore - loads the given files, any of which may be empty. It fails when a
+// given file can not be loaded.
+func NewStore(certFile string, keyFile string, caFile string, zapLog *zap.Logger) (*Store, error) {
+	s := &Store{certFile: certFile, keyFile: keyFile, caFile: caFile, zapLog: zapLog}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Certificate - the current certificate, nil if the store has none.
+func (s *Store) Certificate() *tls.Certificate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cert
+}
+
+// Pool - the current CA bundle, nil if the store has none.
+func (s *Store) Pool() *x509.CertPool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pool
+}
+
+// Reload - reads the files again and uses them if they changed. If they can
+// not be used, for example because only the certificate has been replaced
+// yet, the previous ones are kept and the error returned.
+func (s *Store) Reload() error {
+	contents := [][]byte{}
+	for _, file := range []string{s.certFile, s.keyFile, s.caFile} {
+		if file == "" {
+			contents = append(contents, nil)
+			continue
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		contents = append(contents, content)
+	}
+
+	hash := sha256.New()
+	for _, content := range contents {
+		hash.Write(content)
+	}
+	sum := hash.Sum(nil)
+
+	s.mu.RLock()
+	unchanged := bytes.Equal(sum, s.sum)
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	var cert *tls.Certificate
+	if contents[0] != nil {
+		pair, err := tls.X509KeyPair(contents[0], contents[1])
+		if err != nil {
+			return fmt.Errorf("%s: %v", s.certFile, err)
+		}
+		leaf, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			return fmt.Errorf("%s: %v", s.certFile, err)
+		}
+		pair.Leaf = leaf
+		cert = &pair
+		s.zapLog.Info(fmt.Sprintf("Loaded certificate for %v valid until %s", SANs(leaf), leaf.NotAfter.Format(time.RFC3339)))
+	}
+
+	var pool *x509.CertPool
+	if contents[2] != nil {
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(contents[2]) {
+			return errors.New(s.caFile + ": no certificates found")
+		}
+		s.zapLog.Info(fmt.Sprintf("Loaded CA bundle %s", s.caFile))
+	}
+
+	s.mu.Lock()
+	s.sum, s.cert, s.pool = sum, cert, pool
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Run - reloads the files every interval until ctx is done.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(); err != nil {
+				s.zapLog.Error(fmt.Sprintf("Could not reload certificates, keeping the previous ones, with err %v", err))
+			}
+		}
+	}
+}
+
+// SANs - the subject alternative names of cert: dns names, uris, email
+// addresses and ip addresses.
+func SANs(cert *x509.Certificate) []string {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return sans
+}
@@ -0,0 +1,85 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+)
+
+// ServerConfig - tls config serving the certificate of store. If store has a
+// CA bundle, clients must present a certificate signed by it. Certificate and
+// CA are looked up on every handshake, so reloads apply to new connections.
+func ServerConfig(store *Store) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return store.Certificate(), nil
+		},
+	}
+	if store.Pool() == nil {
+		return cfg
+	}
+
+	// the chain is verified here rather than through ClientCAs, which can
+	// not change once the config is in use
+	cfg.ClientAuth = tls.RequireAnyClientCert
+	cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		return verify(rawCerts, x509.VerifyOptions{
+			Roots:     store.Pool(),
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+	}
+	return cfg
+}
+
+// ClientConfig - tls config for calls to serverName. The server certificate is
+// verified against the CA bundle of store or, without one, the system roots.
+// The certificate of store, if any, is presented to the server.
+func ClientConfig(store *Store, serverName string) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+	if store.Certificate() != nil {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return store.Certificate(), nil
+		}
+	}
+	if store.Pool() == nil {
+		return cfg
+	}
+
+	// the default verification is replaced by one against the current CA
+	// bundle, RootCAs can not change once the config is in use
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		return verify(rawCerts, x509.VerifyOptions{
+			Roots:   store.Pool(),
+			DNSName: serverName,
+		})
+	}
+	return cfg
+}
+
+// verify - verifies the leaf of rawCerts with the rest as intermediates.
+func verify(rawCerts [][]byte, opts x509.VerifyOptions) error {
+	if len(rawCerts) == 0 {
+		return errors.New("No certificate presented")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+
+	opts.Intermediates = x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
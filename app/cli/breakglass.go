@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	alert "github.com/softcorp-io/hqs-privileges-service/alert"
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"go.uber.org/zap"
+)
+
+func breakGlass(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("Usage: breakglass issue|revoke")
+	}
+
+	switch args[0] {
+	case "issue":
+		return issueBreakGlass(zapLog, cfg, args[1:])
+	case "revoke":
+		return revokeBreakGlass(zapLog, cfg, args[1:])
+	}
+	return errors.New("Usage: breakglass issue|revoke")
+}
+
+func issueBreakGlass(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("breakglass issue", flag.ExitOnError)
+	ttl := flags.Duration("ttl", time.Hour, "how long the credential grants root access")
+	uses := flags.Int("uses", 1, "how many requests the credential can be used for")
+	reason := flags.String("reason", "", "why root access is needed, recorded in the audit log")
+	flags.Parse(args)
+
+	return withRepository(zapLog, cfg, func(ctx context.Context, repo *repository.MongoRepository) error {
+		token, glass, err := repo.IssueBreakGlass(ctx, *ttl, *uses, *reason)
+		if err != nil {
+			return err
+		}
+
+		alert.NewLogger().Warn("Break glass credential issued",
+			zap.String("break_glass_id", glass.ID),
+			zap.String("reason", glass.Reason),
+			zap.Int("max_uses", glass.MaxUses),
+			zap.Time("expires_at", glass.ExpiresAt),
+		)
+
+		fmt.Fprintf(os.Stderr, "Break glass credential, shown once. Send it as the token header:\n")
+		fmt.Println(token)
+		return nil
+	})
+}
+
+func revokeBreakGlass(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("breakglass revoke", flag.ExitOnError)
+	flags.Parse(args)
+
+	return withRepository(zapLog, cfg, func(ctx context.Context, repo *repository.MongoRepository) error {
+		revoked, err := repo.RevokeBreakGlass(ctx)
+		if err != nil {
+			return err
+		}
+
+		alert.NewLogger().Warn("Break glass credentials revoked", zap.Int64("revoked", revoked))
+		return printJSON(map[string]int64{"revoked": revoked})
+	})
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+	logging "github.com/softcorp-io/hqs-privileges-service/logging"
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	server "github.com/softcorp-io/hqs-privileges-service/server"
+	"go.uber.org/zap"
+)
+
+type command struct {
+	usage string
+	run   func(zapLog *zap.Logger, cfg *config.Config, args []string) error
+}
+
+var commands = map[string]command{
+	"serve":      {"run the grpc service (default)", nil},
+	"privileges": {"list|get|create|update|delete privileges", privileges},
+	"export":     {"write every privilege and group as json", export},
+	"import":     {"create or replace privileges and groups from json", importData},
+	"migrate":    {"migrate users to the multiple privileges layout", migrate},
+	"check":      {"check and optionally repair users, groups and privileges", check},
+	"bootstrap":  {"create the root and default privileges", bootstrap},
+	"breakglass": {"issue|revoke emergency root credentials", breakGlass},
+	"outbox":     {"dead|requeue privilege events that could not be published", outboxCommand},
+	"config":     {"check the configuration and print it with secrets redacted", nil},
+}
+
+// errFindings - returned by check when findings are left unrepaired.
+var errFindings = errors.New("Findings left unrepaired")
+
+// Run - loads the configuration from flags in front of the subcommand, runs
+// the subcommand named by the first remaining argument against the configured
+// database and returns the exit status. Without a subcommand the service is
+// started. Level is set to the configured log level.
+func Run(zapLog *zap.Logger, level zap.AtomicLevel, args []string) int {
+	cfg, args, err := config.Load(args)
+	if cfg == nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage(os.Stderr)
+		return 2
+	}
+
+	if err == nil {
+		level.UnmarshalText([]byte(cfg.Log.Level))
+	}
+
+	if args[0] == "config" {
+		err = configCommand(cfg, err, args[1:])
+	} else if args[0] == "serve" && err == nil {
+		err = serve(zapLog, level, cfg)
+	} else if err == nil {
+		err = cmd.run(zapLog, cfg, args[1:])
+	}
+	if err != nil {
+		if err != errFindings {
+			fmt.Fprintln(os.Stderr, logging.Scrub(err.Error()))
+		}
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "Usage: hqs-privilege-service [config flags] <command> [flags]")
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-12s %s\n", name, commands[name].usage)
+	}
+}
+
+// withRepository - connects to the configured database for the duration of fn.
+func withRepository(zapLog *zap.Logger, cfg *config.Config, fn func(ctx context.Context, repo *repository.MongoRepository) error) error {
+	client, repo, err := server.Connect(zapLog, cfg, nil)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+
+	return fn(context.Background(), repo)
+}
+
+// printJSON - writes v as indented json to stdout.
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func serve(zapLog *zap.Logger, level zap.AtomicLevel, cfg *config.Config) error {
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	server.Run(zapLog, level, cfg, &wg)
+	wg.Wait()
+	return nil
+}
+
+// configCommand - prints the effective configuration with secrets redacted,
+// followed by every problem found while loading it.
+func configCommand(cfg *config.Config, loadErr error, args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return errors.New("Usage: config check")
+	}
+
+	if err := printJSON(cfg.Redacted()); err != nil {
+		return err
+	}
+	return loadErr
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	server "github.com/softcorp-io/hqs-privileges-service/server"
+	"go.uber.org/zap"
+)
+
+func export(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	output := flags.String("o", "", "file to write to instead of stdout")
+	flags.Parse(args)
+
+	return withRepository(zapLog, cfg, func(ctx context.Context, repo *repository.MongoRepository) error {
+		data, err := repo.Export(ctx)
+		if err != nil {
+			return err
+		}
+
+		if *output == "" {
+			return printJSON(data)
+		}
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	})
+}
+
+func importData(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	input := flags.String("i", "", "file to read from instead of stdin")
+	force := flags.Bool("force", false, "allow replacing trashed privileges and leaving too few privilege administrators")
+	flags.Parse(args)
+
+	var reader io.Reader = os.Stdin
+	if *input != "" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	data := &repository.Export{}
+	if err := json.NewDecoder(reader).Decode(data); err != nil {
+		return fmt.Errorf("Could not read import with err %v", err)
+	}
+
+	return withRepository(zapLog, cfg, func(ctx context.Context, repo *repository.MongoRepository) error {
+		result, err := repo.Import(ctx, data, *force)
+		if err != nil {
+			return err
+		}
+		return printJSON(result)
+	})
+}
+
+func migrate(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Parse(args)
+
+	return withRepository(zapLog, cfg, func(ctx context.Context, repo *repository.MongoRepository) error {
+		migrated, err := repo.MigrateUserPrivileges(ctx)
+		if err != nil {
+			return err
+		}
+		return printJSON(map[string]int64{"migrated": migrated})
+	})
+}
+
+func check(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	repair := flags.Bool("repair", false, "repair findings and audit the repairs")
+	flags.Parse(args)
+
+	return withRepository(zapLog, cfg, func(ctx context.Context, repo *repository.MongoRepository) error {
+		report, err := repo.Check(ctx, *repair)
+		if err != nil {
+			return err
+		}
+		if err := printJSON(report); err != nil {
+			return err
+		}
+		if report.Unrepaired > 0 {
+			return errFindings
+		}
+		return nil
+	})
+}
+
+func bootstrap(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	rootUser := flags.String("root-user", "", "id of a user to give the root privilege")
+	flags.Parse(args)
+
+	return withRepository(zapLog, cfg, func(ctx context.Context, repo *repository.MongoRepository) error {
+		server.Bootstrap(ctx, zapLog, repo)
+		if *rootUser == "" {
+			return nil
+		}
+		return repo.AssignRoot(ctx, *rootUser)
+	})
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"go.uber.org/zap"
+)
+
+func outboxCommand(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("Usage: outbox dead|requeue")
+	}
+	if cfg.Outbox.Sink == config.OutboxNone {
+		return errors.New("The outbox is only used with an OUTBOX_SINK")
+	}
+
+	switch args[0] {
+	case "dead":
+		return deadEvents(zapLog, cfg, args[1:])
+	case "requeue":
+		return requeueEvents(zapLog, cfg, args[1:])
+	}
+	return errors.New("Usage: outbox dead|requeue")
+}
+
+func deadEvents(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("outbox dead", flag.ExitOnError)
+	flags.Parse(args)
+
+	return withRepository(zapLog, cfg, func(ctx context.Context, repo *repository.MongoRepository) error {
+		entries, err := repo.GetDeadEvents(ctx)
+		if err != nil {
+			return err
+		}
+		return printJSON(entries)
+	})
+}
+
+func requeueEvents(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("outbox requeue", flag.ExitOnError)
+	id := flags.String("id", "", "id of the dead event to requeue, every dead event when empty")
+	flags.Parse(args)
+
+	return withRepository(zapLog, cfg, func(ctx context.Context, repo *repository.MongoRepository) error {
+		requeued, err := repo.RequeueDead(ctx, *id)
+		if err != nil {
+			return err
+		}
+		return printJSON(map[string]int64{"requeued": requeued})
+	})
+}
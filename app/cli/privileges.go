@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"go.uber.org/zap"
+)
+
+func privileges(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("Usage: privileges list|get|create|update|delete")
+	}
+
+	switch args[0] {
+	case "list":
+		return listPrivileges(zapLog, cfg, args[1:])
+	case "get":
+		return getPrivilege(zapLog, cfg, args[1:])
+	case "create":
+		return createPrivilege(zapLog, cfg, args[1:])
+	case "update":
+		return updatePrivilege(zapLog, cfg, args[1:])
+	case "delete":
+		return deletePrivilege(zapLog, cfg, args[1:])
+	}
+	return errors.New("Usage: privileges list|get|create|update|delete")
+}
+
+// permissionFlags - defines a bool flag for every permission.
+func permissionFlags(flags *flag.FlagSet) map[string]*bool {
+	permissions := map[string]*bool{}
+	for _, name := range repository.Permissions {
+		permissions[name] = flags.Bool(name, false, "grant "+name)
+	}
+	return permissions
+}
+
+// idArg - returns the single positional id argument.
+func idArg(flags *flag.FlagSet) (string, error) {
+	if flags.NArg() != 1 {
+		return "", errors.New("Exactly one privilege id is required")
+	}
+	return flags.Arg(0), nil
+}
+
+func listPrivileges(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("privileges list", flag.ExitOnError)
+	deleted := flags.Bool("deleted", false, "list privileges in the trash instead")
+	flags.Parse(args)
+
+	return withRepository(zapLog, cfg, func(ctx context.Context, repo *repository.MongoRepository) error {
+		list := repo.GetAll
+		if *deleted {
+			list = repo.GetDeleted
+		}
+		privileges, err := list(ctx)
+		if err != nil {
+			return err
+		}
+		return printJSON(privileges)
+	})
+}
+
+func getPrivilege(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("privileges get", flag.ExitOnError)
+	flags.Parse(args)
+	id, err := idArg(flags)
+	if err != nil {
+		return err
+	}
+
+	return withRepository(zapLog, cfg, func(ctx context.Context, repo *repository.MongoRepository) error {
+		priv, err := repo.Get(ctx, &repository.Privilege{ID: id})
+		if err != nil {
+			return err
+		}
+		return printJSON(priv)
+	})
+}
+
+func createPrivilege(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("privileges create", flag.ExitOnError)
+	name := flags.String("name", "", "name of the privilege")
+	permissions := permissionFlags(flags)
+	flags.Parse(args)
+
+	priv := &repository.Privilege{Name: *name}
+	for permission, value := range permissions {
+		priv.Set(permission, *value)
+	}
+
+	return withRepository(zapLog, cfg, func(ctx context.Context, repo *repository.MongoRepository) error {
+		if err := repo.Create(ctx, priv); err != nil {
+			return err
+		}
+		return printJSON(priv)
+	})
+}
+
+func updatePrivilege(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("privileges update", flag.ExitOnError)
+	name := flags.String("name", "", "new name of the privilege")
+	force := flags.Bool("force", false, "allow leaving too few privilege administrators")
+	permissions := permissionFlags(flags)
+	flags.Parse(args)
+	id, err := idArg(flags)
+	if err != nil {
+		return err
+	}
+
+	return withRepository(zapLog, cfg, func(ctx context.Context, repo *repository.MongoRepository) error {
+		priv, err := repo.Get(ctx, &repository.Privilege{ID: id})
+		if err != nil {
+			return err
+		}
+
+		// only flags given on the command line change the privilege
+		flags.Visit(func(f *flag.Flag) {
+			if f.Name == "name" {
+				priv.Name = *name
+			}
+			if value, ok := permissions[f.Name]; ok {
+				priv.Set(f.Name, *value)
+			}
+		})
+
+		if err := repo.Update(ctx, priv, *force); err != nil {
+			return err
+		}
+		return printJSON(priv)
+	})
+}
+
+func deletePrivilege(zapLog *zap.Logger, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("privileges delete", flag.ExitOnError)
+	force := flags.Bool("force", false, "allow leaving too few privilege administrators")
+	flags.Parse(args)
+	id, err := idArg(flags)
+	if err != nil {
+		return err
+	}
+
+	return withRepository(zapLog, cfg, func(ctx context.Context, repo *repository.MongoRepository) error {
+		return repo.Delete(ctx, &repository.Privilege{ID: id}, *force)
+	})
+}
@@ -0,0 +1,901 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.uber.org/zap/zapcore"
+)
+
+// Config - the configuration of the service, loaded once at start up.
+type Config struct {
+	Service     Service     `json:"service"`
+	Mongo       Mongo       `json:"mongo"`
+	UserService UserService `json:"user_service"`
+	TokenCache  TokenCache  `json:"token_cache"`
+	Auth        Auth        `json:"auth"`
+	Privileges  Privileges  `json:"privileges"`
+	Health      Health      `json:"health"`
+	Metrics     Metrics     `json:"metrics"`
+	Tracing     Tracing     `json:"tracing"`
+	Log         Log         `json:"log"`
+	Limits      Limits      `json:"limits"`
+	Outbox      Outbox      `json:"outbox"`
+}
+
+// Service - ports the service listens on and how it shuts down.
+type Service struct {
+	Port     string `json:"port"`
+	HTTPPort string `json:"http_port"`
+	// HealthPort - serves only the health service, without tls, for probes
+	// that can not present a certificate.
+	HealthPort string `json:"health_port"`
+	// DrainDelay - how long the service keeps serving after reporting not
+	// ready on shutdown, so load balancers notice before it stops.
+	DrainDelay   Duration `json:"drain_delay"`
+	DrainTimeout Duration `json:"drain_timeout"`
+	TLS          TLS      `json:"tls"`
+	// HTTPTimeouts - how long the http gateway waits for a request and its
+	// response, so slow or idle clients can not hold connections forever.
+	HTTPTimeouts HTTPTimeouts `json:"http_timeouts"`
+}
+
+// HTTPTimeouts - how long the http gateway waits for the headers of a request,
+// the whole request, writing the response and the next request on an idle
+// connection.
+type HTTPTimeouts struct {
+	ReadHeader Duration `json:"read_header"`
+	Read       Duration `json:"read"`
+	Write      Duration `json:"write"`
+	Idle       Duration `json:"idle"`
+}
+
+// TLS - the certificate the grpc service and http gateway serve with and, for
+// mutual tls, the CA client certificates must be signed by. Certificates are
+// read again every reload interval so rotated files are picked up.
+type TLS struct {
+	CertFile     string `json:"cert_file"`
+	KeyFile      string `json:"key_file"`
+	ClientCAFile string `json:"client_ca_file"`
+	// AllowedSANs - "service=san" entries naming the client certificates
+	// that may call a grpc service, with "*" as service for every service.
+	// Without entries any certificate signed by the client CA is accepted.
+	AllowedSANs    []string `json:"allowed_sans"`
+	ReloadInterval Duration `json:"reload_interval"`
+}
+
+// Enabled - reports whether the service is served over tls.
+func (t TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// ClientTLS - how an outgoing connection is secured. Without a CA file the
+// system roots are trusted, with a certificate it is presented to the server.
+type ClientTLS struct {
+	Enabled    bool   `json:"enabled"`
+	CAFile     string `json:"ca_file"`
+	CertFile   string `json:"cert_file"`
+	KeyFile    string `json:"key_file"`
+	ServerName string `json:"server_name"`
+}
+
+// Mongo - the database holding privileges, users and groups. Either URI or
+// host, user and password must be set; the remaining settings override what
+// the uri says.
+type Mongo struct {
+	URI            string      `json:"uri"`
+	Host           string      `json:"host"`
+	User           string      `json:"user"`
+	Password       string      `json:"password"`
+	DBName         string      `json:"db_name"`
+	AuthSource     string      `json:"auth_source"`
+	AuthMechanism  string      `json:"auth_mechanism"`
+	TLSCAFile      string      `json:"tls_ca_file"`
+	TLSCertFile    string      `json:"tls_cert_file"`
+	TLSKeyFile     string      `json:"tls_key_file"`
+	MinPoolSize    int64       `json:"min_pool_size"`
+	MaxPoolSize    int64       `json:"max_pool_size"`
+	ReadPreference string      `json:"read_preference"`
+	ReadConcern    string      `json:"read_concern"`
+	WriteConcern   string      `json:"write_concern"`
+	ConnectTimeout Duration    `json:"connect_timeout"`
+	ConnectRetries int64       `json:"connect_retries"`
+	ConnectBackoff Duration    `json:"connect_backoff"`
+	Collections    Collections `json:"collections"`
+}
+
+// Collections - names of the collections used by the repository.
+type Collections struct {
+	Privilege  string `json:"privilege"`
+	User       string `json:"user"`
+	Group      string `json:"group"`
+	Audit      string `json:"audit"`
+	BreakGlass string `json:"break_glass"`
+	Outbox     string `json:"outbox"`
+	Guard      string `json:"guard"`
+}
+
+// UserService - where the user service validating tokens is found and how
+// calls to it are retried and cut short.
+type UserService struct {
+	Host             string    `json:"host"`
+	Port             string    `json:"port"`
+	Timeout          Duration  `json:"timeout"`
+	Retries          int64     `json:"retries"`
+	RetryBackoff     Duration  `json:"retry_backoff"`
+	BreakerThreshold int64     `json:"breaker_threshold"`
+	BreakerCooldown  Duration  `json:"breaker_cooldown"`
+	TLS              ClientTLS `json:"tls"`
+}
+
+// Address - host and port of the user service.
+func (u UserService) Address() string {
+	return u.Host + ":" + u.Port
+}
+
+// TokenCache - how long token validations are remembered.
+type TokenCache struct {
+	Size        int64    `json:"size"`
+	TTL         Duration `json:"ttl"`
+	NegativeTTL Duration `json:"negative_ttl"`
+}
+
+// Auth - how callers are authenticated. Modes are tried in order, the next
+// one only when the previous could not decide, so a local mode can stand in
+// for the user service while it is degraded.
+type Auth struct {
+	Modes []string `json:"modes"`
+	JWT   JWT      `json:"jwt"`
+	// StaticToken - accepted as a privilege administrator by the static
+	// mode, meant for development only.
+	StaticToken string `json:"static_token"`
+	StaticUser  string `json:"static_user"`
+}
+
+// JWT - how tokens are verified locally.
+type JWT struct {
+	KeyFiles    []string `json:"key_files"`
+	HMACSecret  string   `json:"hmac_secret"`
+	JWKSFile    string   `json:"jwks_file"`
+	JWKSURL     string   `json:"jwks_url"`
+	JWKSRefresh Duration `json:"jwks_refresh"`
+	Issuer      string   `json:"issuer"`
+	Audience    string   `json:"audience"`
+}
+
+// Auth modes.
+const (
+	AuthUserService = "user_service"
+	AuthJWT         = "jwt"
+	AuthStatic      = "static"
+)
+
+// Uses - reports whether mode is one of the configured auth modes.
+func (a Auth) Uses(mode string) bool {
+	for _, m := range a.Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Privileges - rules applied to privileges.
+type Privileges struct {
+	MinAdmins      int64    `json:"min_admins"`
+	TrashRetention Duration `json:"trash_retention"`
+}
+
+// Health - how dependencies are probed for the health service.
+type Health struct {
+	Interval         Duration `json:"interval"`
+	CheckUserService bool     `json:"check_user_service"`
+}
+
+// Metrics - where metrics are served and how often privileges are counted
+// for them.
+type Metrics struct {
+	Port          string   `json:"port"`
+	CountInterval Duration `json:"count_interval"`
+}
+
+// Tracing exporters.
+const (
+	TracingNone   = "none"
+	TracingOTLP   = "otlp"
+	TracingStdout = "stdout"
+)
+
+// Tracing - where traces are exported to and what share of them is kept.
+type Tracing struct {
+	Exporter     string  `json:"exporter"`
+	ServiceName  string  `json:"service_name"`
+	OTLPEndpoint string  `json:"otlp_endpoint"`
+	OTLPInsecure bool    `json:"otlp_insecure"`
+	SampleRatio  float64 `json:"sample_ratio"`
+}
+
+// Log - what is logged. The level can also be changed while running.
+type Log struct {
+	Level string `json:"level"`
+}
+
+// Limits - how often each caller may call a method and how many calls to a
+// method may run at once. Methods are full grpc method names, bare method
+// names or * for every other method.
+type Limits struct {
+	Rates       []string `json:"rates"`
+	Concurrency []string `json:"concurrency"`
+}
+
+// Outbox sinks.
+const (
+	OutboxNone    = "none"
+	OutboxWebhook = "webhook"
+	OutboxFile    = "file"
+	OutboxNATS    = "nats"
+)
+
+// Outbox - where privilege change events are published and how delivery is
+// retried. Without a sink no events are recorded.
+type Outbox struct {
+	Sink          string   `json:"sink"`
+	WebhookURL    string   `json:"webhook_url"`
+	WebhookSecret string   `json:"webhook_secret"`
+	FilePath      string   `json:"file_path"`
+	NATSURL       string   `json:"nats_url"`
+	NATSSubject   string   `json:"nats_subject"`
+	PollInterval  Duration `json:"poll_interval"`
+	BatchSize     int64    `json:"batch_size"`
+	MaxAttempts   int64    `json:"max_attempts"`
+	RetryBackoff  Duration `json:"retry_backoff"`
+	Retention     Duration `json:"retention"`
+}
+
+// Duration - a time.Duration written as a string such as "30s".
+type Duration time.Duration
+
+// MarshalJSON - writes d as a duration string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON - reads d from a duration string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Default - the configuration used for everything that is not set.
+func Default() *Config {
+	return &Config{
+		Service: Service{
+			Port:         "9000",
+			DrainDelay:   Duration(5 * time.Second),
+			DrainTimeout: Duration(30 * time.Second),
+			TLS: TLS{
+				ReloadInterval: Duration(time.Minute),
+			},
+			HTTPTimeouts: HTTPTimeouts{
+				ReadHeader: Duration(5 * time.Second),
+				Read:       Duration(30 * time.Second),
+				Write:      Duration(60 * time.Second),
+				Idle:       Duration(2 * time.Minute),
+			},
+		},
+		Mongo: Mongo{
+			ConnectTimeout: Duration(10 * time.Second),
+			ConnectRetries: 5,
+			ConnectBackoff: Duration(time.Second),
+			Collections: Collections{
+				Group:      "privilege_groups",
+				Audit:      "privilege_audit",
+				BreakGlass: "privilege_break_glass",
+				Outbox:     "privilege_outbox",
+				Guard:      "privilege_guard",
+			},
+		},
+		UserService: UserService{
+			Timeout:          Duration(3 * time.Second),
+			Retries:          2,
+			RetryBackoff:     Duration(100 * time.Millisecond),
+			BreakerThreshold: 5,
+			BreakerCooldown:  Duration(30 * time.Second),
+		},
+		TokenCache: TokenCache{
+			Size:        10000,
+			TTL:         Duration(30 * time.Second),
+			NegativeTTL: Duration(5 * time.Second),
+		},
+		Auth: Auth{
+			Modes: []string{AuthUserService},
+			JWT: JWT{
+				JWKSRefresh: Duration(5 * time.Minute),
+			},
+			StaticUser: "dev",
+		},
+		Privileges: Privileges{
+			MinAdmins:      1,
+			TrashRetention: Duration(30 * 24 * time.Hour),
+		},
+		Health: Health{
+			Interval: Duration(10 * time.Second),
+		},
+		Metrics: Metrics{
+			CountInterval: Duration(time.Minute),
+		},
+		Tracing: Tracing{
+			Exporter:    TracingNone,
+			ServiceName: "hqs-privileges-service",
+			SampleRatio: 1,
+		},
+		Log: Log{
+			Level: "info",
+		},
+		Outbox: Outbox{
+			Sink:         OutboxNone,
+			NATSSubject:  "hqs.privileges",
+			PollInterval: Duration(time.Second),
+			BatchSize:    100,
+			MaxAttempts:  10,
+			RetryBackoff: Duration(time.Second),
+			Retention:    Duration(7 * 24 * time.Hour),
+		},
+		Limits: Limits{
+			Rates: []string{"*=50/100", "GetAll=5/10"},
+			Concurrency: []string{
+				"GetAll=8",
+				"ListUsersWithPrivilege=4",
+				"CountUsersPerPrivilege=2",
+				"/hqs_privilege_service.PrivilegeAdminService/Check=1",
+				"/hqs_privilege_service.PrivilegeAdminService/ExportPrivileges=1",
+				"/hqs_privilege_service.PrivilegeAdminService/ImportPrivileges=1",
+			},
+		},
+	}
+}
+
+// setting - a single value that can be set through the environment or a flag.
+type setting struct {
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	field  func(c *Config) interface{}
+}
+
+// settings - every value that can be set outside the config file.
+var settings = []setting{
+	{"SERVICE_PORT", "service-port", "port of the grpc service", false, func(c *Config) interface{} { return &c.Service.Port }},
+	{"HTTP_PORT", "http-port", "port of the http gateway, disabled if empty", false, func(c *Config) interface{} { return &c.Service.HTTPPort }},
+	{"HEALTH_PORT", "health-port", "port serving only the health service without tls, disabled if empty", false, func(c *Config) interface{} { return &c.Service.HealthPort }},
+	{"TLS_CERT_FILE", "tls-cert", "pem file with the certificate served, enables tls", false, func(c *Config) interface{} { return &c.Service.TLS.CertFile }},
+	{"TLS_KEY_FILE", "tls-key", "pem file with the key of the certificate served", false, func(c *Config) interface{} { return &c.Service.TLS.KeyFile }},
+	{"TLS_CLIENT_CA_FILE", "tls-client-ca", "pem file with the CA client certificates must be signed by, enables mutual tls", false, func(c *Config) interface{} { return &c.Service.TLS.ClientCAFile }},
+	{"TLS_ALLOWED_SANS", "tls-allowed-sans", "comma separated service=san entries allowed to call a grpc service, * for every service", false, func(c *Config) interface{} { return &c.Service.TLS.AllowedSANs }},
+	{"TLS_RELOAD_INTERVAL", "tls-reload-interval", "how often certificate files are checked for changes", false, func(c *Config) interface{} { return &c.Service.TLS.ReloadInterval }},
+	{"SHUTDOWN_DRAIN_DELAY", "drain-delay", "how long the service keeps serving after reporting not ready on shutdown", false, func(c *Config) interface{} { return &c.Service.DrainDelay }},
+	{"SHUTDOWN_DRAIN_TIMEOUT", "drain-timeout", "how long in-flight requests may take on shutdown", false, func(c *Config) interface{} { return &c.Service.DrainTimeout }},
+	{"HTTP_READ_HEADER_TIMEOUT", "http-read-header-timeout", "how long the http gateway waits for the headers of a request", false, func(c *Config) interface{} { return &c.Service.HTTPTimeouts.ReadHeader }},
+	{"HTTP_READ_TIMEOUT", "http-read-timeout", "how long the http gateway waits for a whole request", false, func(c *Config) interface{} { return &c.Service.HTTPTimeouts.Read }},
+	{"HTTP_WRITE_TIMEOUT", "http-write-timeout", "how long the http gateway may take to write a response", false, func(c *Config) interface{} { return &c.Service.HTTPTimeouts.Write }},
+	{"HTTP_IDLE_TIMEOUT", "http-idle-timeout", "how long the http gateway keeps idle connections open", false, func(c *Config) interface{} { return &c.Service.HTTPTimeouts.Idle }},
+	{"MONGO_URI", "mongo-uri", "full mongo connection uri, replaces host, user and password", false, func(c *Config) interface{} { return &c.Mongo.URI }},
+	{"MONGO_HOST", "mongo-host", "mongo host", false, func(c *Config) interface{} { return &c.Mongo.Host }},
+	{"MONGO_USER", "mongo-user", "mongo user", false, func(c *Config) interface{} { return &c.Mongo.User }},
+	{"MONGO_PASSWORD", "mongo-password", "mongo password", true, func(c *Config) interface{} { return &c.Mongo.Password }},
+	{"MONGO_DBNAME", "mongo-db", "mongo database name", false, func(c *Config) interface{} { return &c.Mongo.DBName }},
+	{"MONGO_AUTH_SOURCE", "mongo-auth-source", "database holding the mongo user", false, func(c *Config) interface{} { return &c.Mongo.AuthSource }},
+	{"MONGO_AUTH_MECHANISM", "mongo-auth-mechanism", "mongo auth mechanism such as SCRAM-SHA-256 or MONGODB-X509", false, func(c *Config) interface{} { return &c.Mongo.AuthMechanism }},
+	{"MONGO_TLS_CA_FILE", "mongo-tls-ca", "pem file with the CA of the mongo servers", false, func(c *Config) interface{} { return &c.Mongo.TLSCAFile }},
+	{"MONGO_TLS_CERT_FILE", "mongo-tls-cert", "pem file with the mongo client certificate", false, func(c *Config) interface{} { return &c.Mongo.TLSCertFile }},
+	{"MONGO_TLS_KEY_FILE", "mongo-tls-key", "pem file with the mongo client key", false, func(c *Config) interface{} { return &c.Mongo.TLSKeyFile }},
+	{"MONGO_MIN_POOL_SIZE", "mongo-min-pool", "fewest connections kept open, 0 for the driver default", false, func(c *Config) interface{} { return &c.Mongo.MinPoolSize }},
+	{"MONGO_MAX_POOL_SIZE", "mongo-max-pool", "most connections opened, 0 for the driver default", false, func(c *Config) interface{} { return &c.Mongo.MaxPoolSize }},
+	{"MONGO_READ_PREFERENCE", "mongo-read-preference", "primary, primaryPreferred, secondary, secondaryPreferred or nearest", false, func(c *Config) interface{} { return &c.Mongo.ReadPreference }},
+	{"MONGO_READ_CONCERN", "mongo-read-concern", "local, available, majority, linearizable or snapshot", false, func(c *Config) interface{} { return &c.Mongo.ReadConcern }},
+	{"MONGO_WRITE_CONCERN", "mongo-write-concern", "majority or the number of nodes to acknowledge writes", false, func(c *Config) interface{} { return &c.Mongo.WriteConcern }},
+	{"MONGO_CONNECT_TIMEOUT", "mongo-connect-timeout", "how long a single connection attempt may take", false, func(c *Config) interface{} { return &c.Mongo.ConnectTimeout }},
+	{"MONGO_CONNECT_RETRIES", "mongo-connect-retries", "how often connecting is retried on start up", false, func(c *Config) interface{} { return &c.Mongo.ConnectRetries }},
+	{"MONGO_CONNECT_BACKOFF", "mongo-connect-backoff", "wait before the first retry, doubled for every retry", false, func(c *Config) interface{} { return &c.Mongo.ConnectBackoff }},
+	{"MONGO_DB_PRIVILEGE_COLLECTION", "privilege-collection", "collection holding privileges", false, func(c *Config) interface{} { return &c.Mongo.Collections.Privilege }},
+	{"MONGO_DB_USER_COLLECTION", "user-collection", "collection holding users", false, func(c *Config) interface{} { return &c.Mongo.Collections.User }},
+	{"MONGO_DB_GROUP_COLLECTION", "group-collection", "collection holding groups", false, func(c *Config) interface{} { return &c.Mongo.Collections.Group }},
+	{"MONGO_DB_AUDIT_COLLECTION", "audit-collection", "collection holding the audit log", false, func(c *Config) interface{} { return &c.Mongo.Collections.Audit }},
+	{"MONGO_DB_BREAK_GLASS_COLLECTION", "break-glass-collection", "collection holding break glass credentials", false, func(c *Config) interface{} { return &c.Mongo.Collections.BreakGlass }},
+	{"MONGO_DB_OUTBOX_COLLECTION", "outbox-collection", "collection holding events waiting to be published", false, func(c *Config) interface{} { return &c.Mongo.Collections.Outbox }},
+	{"MONGO_DB_GUARD_COLLECTION", "guard-collection", "collection holding the document guarded changes to privilege administrators write to", false, func(c *Config) interface{} { return &c.Mongo.Collections.Guard }},
+	{"USER_SERVICE_IP", "user-service-host", "host of the user service", false, func(c *Config) interface{} { return &c.UserService.Host }},
+	{"USER_SERVICE_PORT", "user-service-port", "port of the user service", false, func(c *Config) interface{} { return &c.UserService.Port }},
+	{"USER_SERVICE_TIMEOUT", "user-service-timeout", "longest a single call to the user service may take", false, func(c *Config) interface{} { return &c.UserService.Timeout }},
+	{"USER_SERVICE_RETRIES", "user-service-retries", "how often a failed call to the user service is retried", false, func(c *Config) interface{} { return &c.UserService.Retries }},
+	{"USER_SERVICE_RETRY_BACKOFF", "user-service-retry-backoff", "longest wait before the first retry, doubled for every retry", false, func(c *Config) interface{} { return &c.UserService.RetryBackoff }},
+	{"USER_SERVICE_BREAKER_THRESHOLD", "user-service-breaker-threshold", "consecutive failures that open the circuit breaker", false, func(c *Config) interface{} { return &c.UserService.BreakerThreshold }},
+	{"USER_SERVICE_BREAKER_COOLDOWN", "user-service-breaker-cooldown", "how long the circuit breaker stays open", false, func(c *Config) interface{} { return &c.UserService.BreakerCooldown }},
+	{"USER_SERVICE_TLS", "user-service-tls", "call the user service over tls", false, func(c *Config) interface{} { return &c.UserService.TLS.Enabled }},
+	{"USER_SERVICE_TLS_CA_FILE", "user-service-tls-ca", "pem file with the CA of the user service, system roots if empty", false, func(c *Config) interface{} { return &c.UserService.TLS.CAFile }},
+	{"USER_SERVICE_TLS_CERT_FILE", "user-service-tls-cert", "pem file with the client certificate presented to the user service", false, func(c *Config) interface{} { return &c.UserService.TLS.CertFile }},
+	{"USER_SERVICE_TLS_KEY_FILE", "user-service-tls-key", "pem file with the key of the client certificate", false, func(c *Config) interface{} { return &c.UserService.TLS.KeyFile }},
+	{"USER_SERVICE_TLS_SERVER_NAME", "user-service-tls-server-name", "name the user service certificate must be issued for, its host if empty", false, func(c *Config) interface{} { return &c.UserService.TLS.ServerName }},
+	{"TOKEN_CACHE_SIZE", "token-cache-size", "most token validations remembered, 0 disables the cache", false, func(c *Config) interface{} { return &c.TokenCache.Size }},
+	{"TOKEN_CACHE_TTL", "token-cache-ttl", "how long a valid token is remembered", false, func(c *Config) interface{} { return &c.TokenCache.TTL }},
+	{"TOKEN_CACHE_NEGATIVE_TTL", "token-cache-negative-ttl", "how long a rejected token is remembered", false, func(c *Config) interface{} { return &c.TokenCache.NegativeTTL }},
+	{"AUTH_MODES", "auth-modes", "comma separated auth modes tried in order: user_service, jwt, static", false, func(c *Config) interface{} { return &c.Auth.Modes }},
+	{"AUTH_JWT_KEY_FILES", "auth-jwt-key-files", "comma separated pem files with public keys jwts are signed with", false, func(c *Config) interface{} { return &c.Auth.JWT.KeyFiles }},
+	{"AUTH_JWT_HMAC_SECRET", "auth-jwt-hmac-secret", "shared secret jwts are signed with", true, func(c *Config) interface{} { return &c.Auth.JWT.HMACSecret }},
+	{"AUTH_JWT_JWKS_FILE", "auth-jwt-jwks-file", "jwks file with the keys jwts are signed with", false, func(c *Config) interface{} { return &c.Auth.JWT.JWKSFile }},
+	{"AUTH_JWT_JWKS_URL", "auth-jwt-jwks-url", "url of the jwks with the keys jwts are signed with", false, func(c *Config) interface{} { return &c.Auth.JWT.JWKSURL }},
+	{"AUTH_JWT_JWKS_REFRESH", "auth-jwt-jwks-refresh", "how often the jwks url is fetched again", false, func(c *Config) interface{} { return &c.Auth.JWT.JWKSRefresh }},
+	{"AUTH_JWT_ISSUER", "auth-jwt-issuer", "required iss claim, if set", false, func(c *Config) interface{} { return &c.Auth.JWT.Issuer }},
+	{"AUTH_JWT_AUDIENCE", "auth-jwt-audience", "required aud claim, if set", false, func(c *Config) interface{} { return &c.Auth.JWT.Audience }},
+	{"AUTH_STATIC_TOKEN", "auth-static-token", "token accepted by the static dev mode", true, func(c *Config) interface{} { return &c.Auth.StaticToken }},
+	{"AUTH_STATIC_USER", "auth-static-user", "user id given to callers of the static dev mode", false, func(c *Config) interface{} { return &c.Auth.StaticUser }},
+	{"MIN_PRIVILEGE_ADMINS", "min-admins", "fewest users that must be able to manage privileges", false, func(c *Config) interface{} { return &c.Privileges.MinAdmins }},
+	{"PRIVILEGE_TRASH_RETENTION", "trash-retention", "how long deleted privileges are kept", false, func(c *Config) interface{} { return &c.Privileges.TrashRetention }},
+	{"HEALTH_CHECK_INTERVAL", "health-interval", "how often dependencies are probed", false, func(c *Config) interface{} { return &c.Health.Interval }},
+	{"HEALTH_CHECK_USER_SERVICE", "health-user-service", "report not ready when the user service is unreachable", false, func(c *Config) interface{} { return &c.Health.CheckUserService }},
+	{"METRICS_PORT", "metrics-port", "port serving prometheus metrics under /metrics, disabled if empty", false, func(c *Config) interface{} { return &c.Metrics.Port }},
+	{"METRICS_COUNT_INTERVAL", "metrics-count-interval", "how often privileges and their users are counted for metrics", false, func(c *Config) interface{} { return &c.Metrics.CountInterval }},
+	{"TRACING_EXPORTER", "tracing-exporter", "where traces are sent: none, otlp or stdout", false, func(c *Config) interface{} { return &c.Tracing.Exporter }},
+	{"TRACING_SERVICE_NAME", "tracing-service-name", "service name spans are reported under", false, func(c *Config) interface{} { return &c.Tracing.ServiceName }},
+	{"TRACING_OTLP_ENDPOINT", "tracing-otlp-endpoint", "host:port of the otlp grpc collector", false, func(c *Config) interface{} { return &c.Tracing.OTLPEndpoint }},
+	{"TRACING_OTLP_INSECURE", "tracing-otlp-insecure", "send traces to the collector without tls", false, func(c *Config) interface{} { return &c.Tracing.OTLPInsecure }},
+	{"TRACING_SAMPLE_RATIO", "tracing-sample-ratio", "share of traces started here that are kept, between 0 and 1", false, func(c *Config) interface{} { return &c.Tracing.SampleRatio }},
+	{"LOG_LEVEL", "log-level", "debug, info, warn or error, security alerts are always written", false, func(c *Config) interface{} { return &c.Log.Level }},
+	{"OUTBOX_SINK", "outbox-sink", "where privilege change events are published: none, webhook, file or nats", false, func(c *Config) interface{} { return &c.Outbox.Sink }},
+	{"OUTBOX_WEBHOOK_URL", "outbox-webhook-url", "url events are posted to", false, func(c *Config) interface{} { return &c.Outbox.WebhookURL }},
+	{"OUTBOX_WEBHOOK_SECRET", "outbox-webhook-secret", "secret events posted to the webhook are signed with", true, func(c *Config) interface{} { return &c.Outbox.WebhookSecret }},
+	{"OUTBOX_FILE_PATH", "outbox-file", "file events are appended to as json lines", false, func(c *Config) interface{} { return &c.Outbox.FilePath }},
+	{"OUTBOX_NATS_URL", "outbox-nats-url", "nats server events are published to", false, func(c *Config) interface{} { return &c.Outbox.NATSURL }},
+	{"OUTBOX_NATS_SUBJECT", "outbox-nats-subject", "subject prefix events are published under, followed by the event type", false, func(c *Config) interface{} { return &c.Outbox.NATSSubject }},
+	{"OUTBOX_POLL_INTERVAL", "outbox-poll-interval", "how often the outbox is checked for events to publish", false, func(c *Config) interface{} { return &c.Outbox.PollInterval }},
+	{"OUTBOX_BATCH_SIZE", "outbox-batch-size", "most events published in one go", false, func(c *Config) interface{} { return &c.Outbox.BatchSize }},
+	{"OUTBOX_MAX_ATTEMPTS", "outbox-max-attempts", "failed publishes before an event is dead-lettered", false, func(c *Config) interface{} { return &c.Outbox.MaxAttempts }},
+	{"OUTBOX_RETRY_BACKOFF", "outbox-retry-backoff", "wait before the first retry, doubled for every retry", false, func(c *Config) interface{} { return &c.Outbox.RetryBackoff }},
+	{"OUTBOX_RETENTION", "outbox-retention", "how long published events are kept", false, func(c *Config) interface{} { return &c.Outbox.Retention }},
+	{"RATE_LIMITS", "rate-limits", "comma separated method=rate/burst entries, calls a second per caller and method", false, func(c *Config) interface{} { return &c.Limits.Rates }},
+	{"CONCURRENCY_LIMITS", "concurrency-limits", "comma separated method=max entries, calls running at once across callers", false, func(c *Config) interface{} { return &c.Limits.Concurrency }},
+}
+
+// ValidationError - every problem found while loading the configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "Invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Load - loads the configuration from, in increasing order of precedence, the
+// defaults, an optional json config file, hqs.env and the environment, and
+// flags in front of args. The remaining args are returned. If the
+// configuration is invalid it is returned together with a ValidationError.
+func Load(args []string) (*Config, []string, error) {
+	flags := flag.NewFlagSet("hqs-privilege-service", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "json config file")
+	envFile := flags.String("env-file", "hqs.env", "env file loaded into the environment")
+	values := map[string]*string{}
+	for _, s := range settings {
+		values[s.flag] = flags.String(s.flag, "", s.usage+" ("+s.env+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	problems := []string{}
+	if err := godotenv.Load(*envFile); err != nil && !os.IsNotExist(err) {
+		problems = append(problems, fmt.Sprintf("%s: %v", *envFile, err))
+	}
+
+	cfg := Default()
+	if *configFile != "" {
+		if err := cfg.readFile(*configFile); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", *configFile, err))
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok {
+			if err := set(s.field(cfg), value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", s.env, err))
+			}
+		}
+	}
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name {
+				if err := set(s.field(cfg), *values[s.flag]); err != nil {
+					problems = append(problems, fmt.Sprintf("-%s: %v", s.flag, err))
+				}
+			}
+		}
+	})
+
+	problems = append(problems, cfg.Validate()...)
+	if len(problems) > 0 {
+		return cfg, flags.Args(), &ValidationError{problems}
+	}
+	return cfg, flags.Args(), nil
+}
+
+func (c *Config) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(c)
+}
+
+// set - parses value into the field pointed to by field.
+func set(field interface{}, value string) error {
+	switch f := field.(type) {
+	case *string:
+		*f = value
+	case *bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		*f = parsed
+	case *int64:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.New("must be a whole number")
+		}
+		*f = parsed
+	case *float64:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		*f = parsed
+	case *[]string:
+		*f = []string{}
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.Trim(part, " "); part != "" {
+				*f = append(*f, part)
+			}
+		}
+	case *Duration:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("must be a duration such as 30s or 720h")
+		}
+		*f = Duration(parsed)
+	}
+	return nil
+}
+
+// Validate - returns every problem with the configuration.
+func (c *Config) Validate() []string {
+	problems := []string{}
+	required := func(value string, name string) {
+		if strings.Trim(value, " ") == "" {
+			problems = append(problems, fmt.Sprintf("Required %s", name))
+		}
+	}
+	positive := func(value Duration, name string) {
+		if value <= 0 {
+			problems = append(problems, fmt.Sprintf("%s must be a positive duration", name))
+		}
+	}
+
+	required(c.Service.Port, "SERVICE_PORT")
+	if c.Mongo.URI == "" {
+		required(c.Mongo.Host, "MONGO_HOST")
+		required(c.Mongo.User, "MONGO_USER")
+		required(c.Mongo.Password, "MONGO_PASSWORD")
+	} else if _, err := url.Parse(c.Mongo.URI); err != nil {
+		problems = append(problems, "MONGO_URI is not a valid uri")
+	}
+	required(c.Mongo.DBName, "MONGO_DBNAME")
+	required(c.Mongo.Collections.Privilege, "MONGO_DB_PRIVILEGE_COLLECTION")
+	required(c.Mongo.Collections.User, "MONGO_DB_USER_COLLECTION")
+	required(c.Mongo.Collections.Group, "MONGO_DB_GROUP_COLLECTION")
+	required(c.Mongo.Collections.Audit, "MONGO_DB_AUDIT_COLLECTION")
+	if c.Outbox.Sink != OutboxNone {
+		required(c.Mongo.Collections.Outbox, "MONGO_DB_OUTBOX_COLLECTION")
+	}
+	required(c.Mongo.Collections.BreakGlass, "MONGO_DB_BREAK_GLASS_COLLECTION")
+	required(c.Mongo.Collections.Guard, "MONGO_DB_GUARD_COLLECTION")
+	if c.Auth.Uses(AuthUserService) || c.Health.CheckUserService {
+		required(c.UserService.Host, "USER_SERVICE_IP")
+		required(c.UserService.Port, "USER_SERVICE_PORT")
+	}
+	problems = append(problems, c.Auth.validate()...)
+
+	if c.Service.HTTPPort != "" && c.Service.HTTPPort == c.Service.Port {
+		problems = append(problems, "HTTP_PORT must differ from SERVICE_PORT")
+	}
+	if c.Service.HealthPort != "" && (c.Service.HealthPort == c.Service.Port || c.Service.HealthPort == c.Service.HTTPPort) {
+		problems = append(problems, "HEALTH_PORT must differ from SERVICE_PORT and HTTP_PORT")
+	}
+	if c.Metrics.Port != "" && (c.Metrics.Port == c.Service.Port || c.Metrics.Port == c.Service.HTTPPort || c.Metrics.Port == c.Service.HealthPort) {
+		problems = append(problems, "METRICS_PORT must differ from SERVICE_PORT, HTTP_PORT and HEALTH_PORT")
+	}
+	problems = append(problems, c.Service.TLS.validate()...)
+	problems = append(problems, c.UserService.TLS.validate()...)
+	problems = append(problems, c.Mongo.validate()...)
+	problems = append(problems, c.Tracing.validate()...)
+	problems = append(problems, c.Limits.validate()...)
+	problems = append(problems, c.Outbox.validate()...)
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL %q is unknown", c.Log.Level))
+	}
+	if c.UserService.Retries < 0 {
+		problems = append(problems, "USER_SERVICE_RETRIES must be a non-negative number")
+	}
+	if c.UserService.BreakerThreshold < 1 {
+		problems = append(problems, "USER_SERVICE_BREAKER_THRESHOLD must be at least 1")
+	}
+	if c.TokenCache.Size < 0 || c.TokenCache.TTL < 0 || c.TokenCache.NegativeTTL < 0 {
+		problems = append(problems, "TOKEN_CACHE_SIZE, TOKEN_CACHE_TTL and TOKEN_CACHE_NEGATIVE_TTL must not be negative")
+	}
+	if c.Privileges.MinAdmins < 1 {
+		problems = append(problems, "MIN_PRIVILEGE_ADMINS must be at least 1")
+	}
+	if c.Service.DrainDelay < 0 {
+		problems = append(problems, "SHUTDOWN_DRAIN_DELAY must not be negative")
+	}
+	positive(c.Service.DrainTimeout, "SHUTDOWN_DRAIN_TIMEOUT")
+	positive(c.Service.HTTPTimeouts.ReadHeader, "HTTP_READ_HEADER_TIMEOUT")
+	positive(c.Service.HTTPTimeouts.Read, "HTTP_READ_TIMEOUT")
+	positive(c.Service.HTTPTimeouts.Write, "HTTP_WRITE_TIMEOUT")
+	positive(c.Service.HTTPTimeouts.Idle, "HTTP_IDLE_TIMEOUT")
+	positive(c.Mongo.ConnectTimeout, "MONGO_CONNECT_TIMEOUT")
+	positive(c.Mongo.ConnectBackoff, "MONGO_CONNECT_BACKOFF")
+	positive(c.UserService.Timeout, "USER_SERVICE_TIMEOUT")
+	positive(c.UserService.RetryBackoff, "USER_SERVICE_RETRY_BACKOFF")
+	positive(c.UserService.BreakerCooldown, "USER_SERVICE_BREAKER_COOLDOWN")
+	positive(c.Privileges.TrashRetention, "PRIVILEGE_TRASH_RETENTION")
+	positive(c.Health.Interval, "HEALTH_CHECK_INTERVAL")
+	positive(c.Metrics.CountInterval, "METRICS_COUNT_INTERVAL")
+
+	return problems
+}
+
+// validate - checks the connection options that are given as names.
+func (m *Mongo) validate() []string {
+	problems := []string{}
+
+	if (m.TLSCertFile == "") != (m.TLSKeyFile == "") {
+		problems = append(problems, "MONGO_TLS_CERT_FILE and MONGO_TLS_KEY_FILE must be set together")
+	}
+	if m.MinPoolSize < 0 || m.MaxPoolSize < 0 {
+		problems = append(problems, "MONGO_MIN_POOL_SIZE and MONGO_MAX_POOL_SIZE must be non-negative numbers")
+	} else if m.MaxPoolSize > 0 && m.MinPoolSize > m.MaxPoolSize {
+		problems = append(problems, "MONGO_MIN_POOL_SIZE must not exceed MONGO_MAX_POOL_SIZE")
+	}
+	if m.ConnectRetries < 0 {
+		problems = append(problems, "MONGO_CONNECT_RETRIES must be a non-negative number")
+	}
+
+	switch m.ReadPreference {
+	case "", "primary", "primaryPreferred", "secondary", "secondaryPreferred", "nearest":
+	default:
+		problems = append(problems, fmt.Sprintf("MONGO_READ_PREFERENCE %q is unknown", m.ReadPreference))
+	}
+	switch m.ReadConcern {
+	case "", "local", "available", "majority", "linearizable", "snapshot":
+	default:
+		problems = append(problems, fmt.Sprintf("MONGO_READ_CONCERN %q is unknown", m.ReadConcern))
+	}
+	if m.WriteConcern != "" && m.WriteConcern != "majority" {
+		if w, err := strconv.Atoi(m.WriteConcern); err != nil || w < 0 {
+			problems = append(problems, "MONGO_WRITE_CONCERN must be majority or a non-negative number")
+		}
+	}
+
+	return problems
+}
+
+// validate - checks that the tls files come in working combinations and that
+// every allowed san names a service.
+func (t *TLS) validate() []string {
+	problems := []string{}
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		problems = append(problems, "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if t.ClientCAFile != "" && t.CertFile == "" {
+		problems = append(problems, "TLS_CLIENT_CA_FILE needs TLS_CERT_FILE")
+	}
+	if len(t.AllowedSANs) > 0 && t.ClientCAFile == "" {
+		problems = append(problems, "TLS_ALLOWED_SANS needs TLS_CLIENT_CA_FILE")
+	}
+	for _, entry := range t.AllowedSANs {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			problems = append(problems, fmt.Sprintf("TLS_ALLOWED_SANS %q must look like service=san", entry))
+		}
+	}
+	if t.ReloadInterval <= 0 {
+		problems = append(problems, "TLS_RELOAD_INTERVAL must be a positive duration")
+	}
+
+	return problems
+}
+
+// validate - checks that the sink is known and has what it needs.
+func (o *Outbox) validate() []string {
+	problems := []string{}
+
+	switch o.Sink {
+	case OutboxNone:
+		return problems
+	case OutboxWebhook:
+		if parsed, err := url.Parse(o.WebhookURL); err != nil || parsed.Host == "" {
+			problems = append(problems, "OUTBOX_SINK webhook needs a valid OUTBOX_WEBHOOK_URL")
+		}
+	case OutboxFile:
+		if o.FilePath == "" {
+			problems = append(problems, "OUTBOX_SINK file needs OUTBOX_FILE_PATH")
+		}
+	case OutboxNATS:
+		if o.NATSURL == "" || o.NATSSubject == "" {
+			problems = append(problems, "OUTBOX_SINK nats needs OUTBOX_NATS_URL and OUTBOX_NATS_SUBJECT")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("OUTBOX_SINK %q is unknown", o.Sink))
+	}
+	if o.PollInterval <= 0 || o.RetryBackoff <= 0 || o.Retention <= 0 {
+		problems = append(problems, "OUTBOX_POLL_INTERVAL, OUTBOX_RETRY_BACKOFF and OUTBOX_RETENTION must be positive durations")
+	}
+	if o.BatchSize < 1 || o.MaxAttempts < 1 {
+		problems = append(problems, "OUTBOX_BATCH_SIZE and OUTBOX_MAX_ATTEMPTS must be at least 1")
+	}
+
+	return problems
+}
+
+// validate - checks that every limit names a method and a usable value.
+func (l *Limits) validate() []string {
+	problems := []string{}
+
+	for _, entry := range l.Rates {
+		parts := strings.SplitN(entry, "=", 2)
+		valid := len(parts) == 2 && parts[0] != ""
+		if valid {
+			values := strings.SplitN(parts[1], "/", 2)
+			valid = len(values) == 2
+			if valid {
+				rate, rateErr := strconv.ParseFloat(values[0], 64)
+				burst, burstErr := strconv.ParseInt(values[1], 10, 64)
+				valid = rateErr == nil && burstErr == nil && rate > 0 && burst >= 1
+			}
+		}
+		if !valid {
+			problems = append(problems, fmt.Sprintf("RATE_LIMITS %q must look like method=rate/burst with a positive rate and burst", entry))
+		}
+	}
+	for _, entry := range l.Concurrency {
+		parts := strings.SplitN(entry, "=", 2)
+		valid := len(parts) == 2 && parts[0] != ""
+		if valid {
+			max, err := strconv.ParseInt(parts[1], 10, 64)
+			valid = err == nil && max >= 1
+		}
+		if !valid {
+			problems = append(problems, fmt.Sprintf("CONCURRENCY_LIMITS %q must look like method=max with max at least 1", entry))
+		}
+	}
+
+	return problems
+}
+
+// validate - checks that the exporter is known and has what it needs.
+func (t *Tracing) validate() []string {
+	problems := []string{}
+
+	switch t.Exporter {
+	case TracingNone, TracingStdout:
+	case TracingOTLP:
+		if t.OTLPEndpoint == "" {
+			problems = append(problems, "TRACING_EXPORTER otlp needs TRACING_OTLP_ENDPOINT")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("TRACING_EXPORTER %q is unknown", t.Exporter))
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		problems = append(problems, "TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+
+	return problems
+}
+
+// validate - checks that client tls files are only given with tls enabled.
+func (t *ClientTLS) validate() []string {
+	problems := []string{}
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		problems = append(problems, "USER_SERVICE_TLS_CERT_FILE and USER_SERVICE_TLS_KEY_FILE must be set together")
+	}
+	if !t.Enabled && (t.CAFile != "" || t.CertFile != "" || t.ServerName != "") {
+		problems = append(problems, "USER_SERVICE_TLS_* files need USER_SERVICE_TLS=true")
+	}
+
+	return problems
+}
+
+// validate - checks the auth modes and what each of them needs.
+func (a *Auth) validate() []string {
+	problems := []string{}
+
+	if len(a.Modes) == 0 {
+		problems = append(problems, "AUTH_MODES must name at least one mode")
+	}
+	for _, mode := range a.Modes {
+		switch mode {
+		case AuthUserService:
+		case AuthJWT:
+			if len(a.JWT.KeyFiles) == 0 && a.JWT.HMACSecret == "" && a.JWT.JWKSFile == "" && a.JWT.JWKSURL == "" {
+				problems = append(problems, "The jwt auth mode needs AUTH_JWT_KEY_FILES, AUTH_JWT_HMAC_SECRET, AUTH_JWT_JWKS_FILE or AUTH_JWT_JWKS_URL")
+			}
+			if a.JWT.JWKSURL != "" && a.JWT.JWKSRefresh <= 0 {
+				problems = append(problems, "AUTH_JWT_JWKS_REFRESH must be a positive duration")
+			}
+		case AuthStatic:
+			if len(a.StaticToken) < 16 {
+				problems = append(problems, "The static auth mode needs an AUTH_STATIC_TOKEN of at least 16 characters")
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("AUTH_MODES %q is unknown", mode))
+		}
+	}
+
+	return problems
+}
+
+// Redacted - a copy of the configuration with secrets blanked out, safe to
+// print or log.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.Auth.Modes = append([]string{}, c.Auth.Modes...)
+	redacted.Auth.JWT.KeyFiles = append([]string{}, c.Auth.JWT.KeyFiles...)
+	redacted.Service.TLS.AllowedSANs = append([]string{}, c.Service.TLS.AllowedSANs...)
+	redacted.Limits.Rates = append([]string{}, c.Limits.Rates...)
+	redacted.Limits.Concurrency = append([]string{}, c.Limits.Concurrency...)
+	for _, s := range settings {
+		if value, ok := s.field(&redacted).(*string); ok && s.secret && *value != "" {
+			*value = "[REDACTED]"
+		}
+	}
+	redacted.Mongo.URI = RedactURI(redacted.Mongo.URI)
+	redacted.Outbox.WebhookURL = RedactURI(redacted.Outbox.WebhookURL)
+	redacted.Outbox.NATSURL = RedactURI(redacted.Outbox.NATSURL)
+	return &redacted
+}
+
+// RedactURI - blanks out the password of a connection uri.
+func RedactURI(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.User == nil {
+		return uri
+	}
+	if _, ok := parsed.User.Password(); !ok {
+		return uri
+	}
+	parsed.User = url.UserPassword(parsed.User.Username(), "REDACTED")
+	return parsed.String()
+}
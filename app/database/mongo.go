@@ -2,58 +2,198 @@ package database
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
-	"os"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"time"
 
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 	"go.uber.org/zap"
 )
 
-// MongoEnv - mongo environment variables.
-type MongoEnv struct {
-	Host     string
-	User     string
-	Password string
-	DBname   string
+// maxConnectBackoff - longest wait between two connection attempts.
+const maxConnectBackoff = 30 * time.Second
+
+// URI - the connection uri of cfg. Without a full uri one is built for a
+// mongodb+srv host, as the service has always done.
+func URI(cfg config.Mongo) string {
+	if cfg.URI != "" {
+		return cfg.URI
+	}
+	uri := url.URL{
+		Scheme:   "mongodb+srv",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     cfg.Host,
+		Path:     "/" + cfg.DBName,
+		RawQuery: "retryWrites=true&w=majority",
+	}
+	return uri.String()
 }
 
-// GetMongoEnv - returns the environment of mongodb.
-func GetMongoEnv() (*MongoEnv, error) {
-	host, check := os.LookupEnv("MONGO_HOST")
-	if !check {
-		return nil, errors.New("Required MONGO_HOST")
+// ClientOptions - builds the driver options for cfg. Settings given
+// explicitly override those in the uri.
+func ClientOptions(cfg config.Mongo) (*options.ClientOptions, error) {
+	opts := options.Client().ApplyURI(URI(cfg))
+
+	if cfg.AuthSource != "" || cfg.AuthMechanism != "" {
+		credential := options.Credential{}
+		if opts.Auth != nil {
+			credential = *opts.Auth
+		}
+		if cfg.AuthSource != "" {
+			credential.AuthSource = cfg.AuthSource
+		}
+		if cfg.AuthMechanism != "" {
+			credential.AuthMechanism = cfg.AuthMechanism
+		}
+		opts.SetAuth(credential)
+	}
+
+	if cfg.TLSCAFile != "" || cfg.TLSCertFile != "" {
+		tlsConfig, err := tlsConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	if cfg.MinPoolSize > 0 {
+		opts.SetMinPoolSize(uint64(cfg.MinPoolSize))
 	}
-	user, check := os.LookupEnv("MONGO_USER")
-	if !check {
-		return nil, errors.New("Required MONGO_USER")
+	if cfg.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(uint64(cfg.MaxPoolSize))
 	}
-	dbname, check := os.LookupEnv("MONGO_DBNAME")
-	if !check {
-		return nil, errors.New("Required MONGO_PASSWORD")
+
+	if cfg.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(cfg.ReadPreference)
+		if err != nil {
+			return nil, err
+		}
+		pref, err := readpref.New(mode)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetReadPreference(pref)
+	}
+	if cfg.ReadConcern != "" {
+		opts.SetReadConcern(readconcern.New(readconcern.Level(cfg.ReadConcern)))
+	}
+	if cfg.WriteConcern != "" {
+		if cfg.WriteConcern == "majority" {
+			opts.SetWriteConcern(writeconcern.New(writeconcern.WMajority()))
+		} else {
+			w, err := strconv.Atoi(cfg.WriteConcern)
+			if err != nil {
+				return nil, err
+			}
+			opts.SetWriteConcern(writeconcern.New(writeconcern.W(w)))
+		}
+	}
+
+	return opts, nil
+}
+
+// tlsConfig - trusts the CA in cfg and presents the client certificate in
+// cfg, if given.
+func tlsConfig(cfg config.Mongo) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if cfg.TLSCAFile != "" {
+		ca, err := ioutil.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("No certificates found in MONGO_TLS_CA_FILE")
+		}
+		tlsConfig.RootCAs = pool
 	}
-	password, check := os.LookupEnv("MONGO_PASSWORD")
-	if !check {
-		return nil, errors.New("Required MONGO_PASSWORD")
+
+	if cfg.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
-	return &MongoEnv{host, user, password, dbname}, nil
+
+	return tlsConfig, nil
 }
 
 // NewMongoDatabase - creates a connection to a mongo database.
-func NewMongoDatabase(ctx context.Context, zapLog *zap.Logger, uri string) (*mongo.Client, error) {
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(
-		uri,
-	))
+func NewMongoDatabase(ctx context.Context, zapLog *zap.Logger, opts *options.ClientOptions) (*mongo.Client, error) {
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
-		zapLog.Error(fmt.Sprintf("Could not connect to mongo with uri %s", uri))
+		zapLog.Error(fmt.Sprintf("Could not connect to mongo with err %v", err))
 		return nil, err
 	}
 
-	if err := client.Ping(context.Background(), nil); err != nil {
+	if err := client.Ping(ctx, nil); err != nil {
 		zapLog.Error(fmt.Sprintf("Could not ping client with err %v", err))
+		client.Disconnect(context.Background())
 		return nil, err
 	}
 
 	return client, err
 }
+
+// Connect - connects to the database in cfg, retrying with exponential
+// backoff so the service survives mongo starting after it. Commands are
+// reported to monitor, if given.
+func Connect(zapLog *zap.Logger, cfg config.Mongo, monitor *event.CommandMonitor) (*mongo.Client, error) {
+	opts, err := ClientOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if monitor != nil {
+		opts.SetMonitor(monitor)
+	}
+
+	backoff := time.Duration(cfg.ConnectBackoff)
+	for attempt := int64(0); ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ConnectTimeout))
+		client, err := NewMongoDatabase(ctx, zapLog, opts)
+		cancel()
+		if err == nil {
+			return client, nil
+		}
+		if attempt >= cfg.ConnectRetries {
+			return nil, err
+		}
+
+		zapLog.Warn(fmt.Sprintf("Retrying mongo connection in %v, attempt %d of %d", backoff, attempt+1, cfg.ConnectRetries))
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
+}
+
+// RequireTransactions - fails unless client is connected to a replica set or a
+// sharded cluster, as transactions are not supported by a standalone mongo.
+func RequireTransactions(ctx context.Context, client *mongo.Client) error {
+	var result struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.M{"isMaster": 1}).Decode(&result); err != nil {
+		return err
+	}
+	if result.SetName == "" && result.Msg != "isdbgrid" {
+		return errors.New("Mongo must run as a replica set or a sharded cluster, transactions are not supported by a standalone server")
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+	handler "github.com/softcorp-io/hqs-privileges-service/handler"
+	logging "github.com/softcorp-io/hqs-privileges-service/logging"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Gateway - serves the privilege service as json over http. Every call goes
+// through the same interceptors as the grpc server, as if it were the grpc
+// method the route maps to.
+type Gateway struct {
+	handler     *handler.Handler
+	interceptor grpc.UnaryServerInterceptor
+	zapLog      *zap.Logger
+	routes      []*route
+}
+
+// NewGateway - returns a Gateway for the given handler. Interceptors run in
+// the order given, the first one outermost.
+func NewGateway(handle *handler.Handler, zapLog *zap.Logger, interceptors ...grpc.UnaryServerInterceptor) *Gateway {
+	g := &Gateway{handler: handle, interceptor: chain(interceptors), zapLog: zapLog}
+	g.routes = g.privilegeRoutes()
+	return g
+}
+
+// errorBody - body of every error response.
+type errorBody struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code            string            `json:"code"`
+	Message         string            `json:"message"`
+	Reason          string            `json:"reason,omitempty"`
+	FieldViolations []*fieldViolation `json:"field_violations,omitempty"`
+}
+
+type fieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// chain - runs interceptors as one, the first one outermost.
+func chain(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handle grpc.UnaryHandler) (interface{}, error) {
+		next := handle
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, inner)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// ServeHTTP - routes a request to the matching operation.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet && req.URL.Path == "/openapi.json" {
+		writeJSON(w, http.StatusOK, g.openAPI())
+		return
+	}
+
+	segments := splitPath(req.URL.Path)
+	allowed := []string{}
+	for _, rt := range g.routes {
+		params, ok := rt.match(segments)
+		if !ok {
+			continue
+		}
+		if rt.method != req.Method {
+			allowed = append(allowed, rt.method)
+			continue
+		}
+
+		info := &grpc.UnaryServerInfo{
+			Server:     g.handler,
+			FullMethod: "/" + handler.PrivilegeServiceName + "/" + rt.operationID,
+		}
+		// the gateway can not send grpc headers, so the request id is settled
+		// and sent back here
+		requestID := logging.RequestID(req.Header.Get(logging.RequestIDHeader))
+		w.Header().Set(logging.RequestIDHeader, requestID)
+
+		result, err := g.interceptor(incomingContext(req, requestID), req, info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			return rt.serve(ctx, req, params)
+		})
+		if err != nil {
+			g.writeError(w, err)
+			return
+		}
+		writeJSON(w, rt.status, result)
+		return
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		g.writeError(w, status.Error(codes.Unimplemented, "Method not allowed"))
+		return
+	}
+	g.writeError(w, status.Error(codes.NotFound, "Route not found"))
+}
+
+// traceHeaders - headers carrying the trace of the caller.
+var traceHeaders = []string{"traceparent", "tracestate", "baggage"}
+
+// incomingContext - passes the token, force and trace headers, the request id
+// and the client certificate on the way the grpc server would, so http calls
+// are authenticated, traced and logged the same way.
+func incomingContext(req *http.Request, requestID string) context.Context {
+	ctx := req.Context()
+	if req.TLS != nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{State: *req.TLS}})
+	}
+
+	md := metadata.Pairs(logging.RequestIDHeader, requestID)
+	if token := req.Header.Get("token"); token != "" {
+		md.Set("token", token)
+	}
+	if authorization := req.Header.Get("Authorization"); authorization != "" {
+		md.Set("authorization", authorization)
+	}
+	if force := req.Header.Get("force"); force != "" {
+		md.Set("force", force)
+	}
+	for _, header := range traceHeaders {
+		if value := req.Header.Get(header); value != "" {
+			md.Set(header, value)
+		}
+	}
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+// writeError - writes err with an http status matching its grpc code, along
+// with the reason and field violations attached to it. When the caller is
+// asked to retry later, Retry-After says when.
+func (g *Gateway) writeError(w http.ResponseWriter, err error) {
+	s := status.Convert(handler.StatusError(err))
+	detail := errorDetail{Code: s.Code().String(), Message: s.Message()}
+	for _, d := range s.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			detail.Reason = d.Reason
+		case *errdetails.BadRequest:
+			for _, violation := range d.FieldViolations {
+				detail.FieldViolations = append(detail.FieldViolations, &fieldViolation{violation.Field, violation.Description})
+			}
+		case *errdetails.RetryInfo:
+			if delay := d.GetRetryDelay(); delay != nil {
+				w.Header().Set("Retry-After", strconv.FormatInt(delay.GetSeconds(), 10))
+			}
+		}
+	}
+
+	writeJSON(w, httpStatus(s.Code()), &errorBody{detail})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// httpStatus - maps a grpc code to the http status used for it.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusMethodNotAllowed
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Canceled:
+		return 499
+	}
+	return http.StatusInternalServerError
+}
+
+// ListenAndServe - serves the gateway on port until the server is closed,
+// over https if tlsConfig is given, cutting requests short after timeouts.
+func (g *Gateway) ListenAndServe(port string, tlsConfig *tls.Config, timeouts config.HTTPTimeouts) *http.Server {
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%s", port),
+		Handler:           g,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: time.Duration(timeouts.ReadHeader),
+		ReadTimeout:       time.Duration(timeouts.Read),
+		WriteTimeout:      time.Duration(timeouts.Write),
+		IdleTimeout:       time.Duration(timeouts.Idle),
+	}
+	go func() {
+		g.zapLog.Info(fmt.Sprintf("HTTP gateway running on port: %s", port))
+		var err error
+		if tlsConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			g.zapLog.Error(fmt.Sprintf("HTTP gateway failed with err %v", err))
+		}
+	}()
+	return srv
+}
@@ -0,0 +1,145 @@
+package gateway
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+)
+
+// openAPI - builds the openapi document of the gateway from its route table,
+// so the document cannot drift from the routes actually served.
+func (g *Gateway) openAPI() map[string]interface{} {
+	paths := map[string]interface{}{}
+	for _, rt := range g.routes {
+		path, ok := paths[rt.path].(map[string]interface{})
+		if !ok {
+			path = map[string]interface{}{}
+			paths[rt.path] = path
+		}
+
+		operation := map[string]interface{}{
+			"operationId": rt.operationID,
+			"summary":     rt.summary,
+			"security":    []interface{}{map[string]interface{}{"token": []string{}}},
+			"responses": map[string]interface{}{
+				strconv.Itoa(rt.status): map[string]interface{}{
+					"description": rt.summary,
+					"content":     jsonContent(responseSchema(rt.response)),
+				},
+				"default": map[string]interface{}{
+					"description": "Error",
+					"content":     jsonContent(ref("Error")),
+				},
+			},
+		}
+
+		parameters := []interface{}{}
+		for _, part := range splitPath(rt.path) {
+			if strings.HasPrefix(part, "{") {
+				parameters = append(parameters, map[string]interface{}{
+					"name":     strings.Trim(part, "{}"),
+					"in":       "path",
+					"required": true,
+					"schema":   map[string]interface{}{"type": "string"},
+				})
+			}
+		}
+		if rt.method == http.MethodPut || rt.method == http.MethodDelete {
+			parameters = append(parameters, map[string]interface{}{
+				"name":        "force",
+				"in":          "header",
+				"description": "Set to true to override the privilege administrator lockout check",
+				"schema":      map[string]interface{}{"type": "boolean"},
+			})
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if rt.body {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(ref("Privilege")),
+			}
+		}
+
+		path[strings.ToLower(rt.method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "HQS privilege service",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"token": map[string]interface{}{"type": "apiKey", "in": "header", "name": "token"},
+			},
+			"schemas": map[string]interface{}{
+				"Privilege": schemaOf(reflect.TypeOf(repository.Privilege{})),
+				"Privileges": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"privileges": map[string]interface{}{"type": "array", "items": ref("Privilege")},
+					},
+				},
+				"Error": schemaOf(reflect.TypeOf(errorBody{})),
+			},
+		},
+	}
+}
+
+func responseSchema(response string) map[string]interface{} {
+	switch response {
+	case responsePrivilege:
+		return ref("Privilege")
+	case responsePrivileges:
+		return ref("Privileges")
+	}
+	return map[string]interface{}{"type": "object"}
+}
+
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// schemaOf - describes t as an openapi schema using its json tags.
+func schemaOf(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			properties[name] = schemaOf(t.Field(i).Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+	return map[string]interface{}{}
+}
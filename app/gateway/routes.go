@@ -0,0 +1,173 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	privilegeProto "github.com/softcorp-io/hqs_proto/go_hqs/hqs_privilege_service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxBodySize - largest request body accepted by the gateway.
+const maxBodySize = 1 << 20
+
+// route - a single http operation mapped onto a handler method. Path segments
+// in braces are parameters.
+type route struct {
+	method      string
+	path        string
+	operationID string
+	summary     string
+	body        bool
+	response    string
+	status      int
+	serve       func(ctx context.Context, req *http.Request, params map[string]string) (interface{}, error)
+}
+
+// Responses a route can return, used to describe them in the openapi document.
+const (
+	responseEmpty      = "empty"
+	responsePrivilege  = "privilege"
+	responsePrivileges = "privileges"
+)
+
+// privilegesResponse - body returned when listing privileges.
+type privilegesResponse struct {
+	Privileges []*repository.Privilege `json:"privileges"`
+}
+
+// privilegeRoutes - the route table. Fixed paths come before parameterised
+// ones so /privileges/root is not read as an id.
+func (g *Gateway) privilegeRoutes() []*route {
+	return []*route{
+		{
+			method: http.MethodGet, path: "/privileges", operationID: "GetAll",
+			summary: "List every privilege", response: responsePrivileges, status: http.StatusOK,
+			serve: func(ctx context.Context, req *http.Request, params map[string]string) (interface{}, error) {
+				resp, err := g.handler.GetAll(ctx, &privilegeProto.Request{})
+				if err != nil {
+					return nil, err
+				}
+				return &privilegesResponse{repository.MarshalPrivilegeCollection(resp.Privileges)}, nil
+			},
+		},
+		{
+			method: http.MethodPost, path: "/privileges", operationID: "Create",
+			summary: "Create a privilege", body: true, response: responseEmpty, status: http.StatusCreated,
+			serve: func(ctx context.Context, req *http.Request, params map[string]string) (interface{}, error) {
+				priv, err := decodePrivilege(req)
+				if err != nil {
+					return nil, err
+				}
+				if _, err := g.handler.Create(ctx, priv); err != nil {
+					return nil, err
+				}
+				return struct{}{}, nil
+			},
+		},
+		{
+			method: http.MethodGet, path: "/privileges/root", operationID: "GetRoot",
+			summary: "Get the root privilege", response: responsePrivilege, status: http.StatusOK,
+			serve: func(ctx context.Context, req *http.Request, params map[string]string) (interface{}, error) {
+				resp, err := g.handler.GetRoot(ctx, &privilegeProto.Request{})
+				if err != nil {
+					return nil, err
+				}
+				return repository.MarshalPrivilege(resp.Privilege), nil
+			},
+		},
+		{
+			method: http.MethodGet, path: "/privileges/default", operationID: "GetDefault",
+			summary: "Get the default privilege", response: responsePrivilege, status: http.StatusOK,
+			serve: func(ctx context.Context, req *http.Request, params map[string]string) (interface{}, error) {
+				resp, err := g.handler.GetDefault(ctx, &privilegeProto.Request{})
+				if err != nil {
+					return nil, err
+				}
+				return repository.MarshalPrivilege(resp.Privilege), nil
+			},
+		},
+		{
+			method: http.MethodGet, path: "/privileges/{id}", operationID: "Get",
+			summary: "Get a privilege by id", response: responsePrivilege, status: http.StatusOK,
+			serve: func(ctx context.Context, req *http.Request, params map[string]string) (interface{}, error) {
+				resp, err := g.handler.Get(ctx, &privilegeProto.Privilege{Id: params["id"]})
+				if err != nil {
+					return nil, err
+				}
+				return repository.MarshalPrivilege(resp.Privilege), nil
+			},
+		},
+		{
+			method: http.MethodPut, path: "/privileges/{id}", operationID: "Update",
+			summary: "Update a privilege", body: true, response: responseEmpty, status: http.StatusOK,
+			serve: func(ctx context.Context, req *http.Request, params map[string]string) (interface{}, error) {
+				priv, err := decodePrivilege(req)
+				if err != nil {
+					return nil, err
+				}
+				priv.Id = params["id"]
+				if _, err := g.handler.Update(ctx, priv); err != nil {
+					return nil, err
+				}
+				return struct{}{}, nil
+			},
+		},
+		{
+			method: http.MethodDelete, path: "/privileges/{id}", operationID: "Delete",
+			summary: "Delete a privilege", response: responseEmpty, status: http.StatusOK,
+			serve: func(ctx context.Context, req *http.Request, params map[string]string) (interface{}, error) {
+				if _, err := g.handler.Delete(ctx, &privilegeProto.Privilege{Id: params["id"]}); err != nil {
+					return nil, err
+				}
+				return struct{}{}, nil
+			},
+		},
+	}
+}
+
+// match - reports whether segments match the route and returns its parameters.
+func (rt *route) match(segments []string) (map[string]string, bool) {
+	pattern := splitPath(rt.path)
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, part := range pattern {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[strings.Trim(part, "{}")] = segments[i]
+			continue
+		}
+		if part != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// decodePrivilege - reads a privilege from the json body of req.
+func decodePrivilege(req *http.Request) (*privilegeProto.Privilege, error) {
+	priv := repository.Privilege{}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, req.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&priv); err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid request body: %v", err))
+	}
+
+	return repository.UnmarshalPrivilege(&priv), nil
+}
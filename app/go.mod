@@ -4,11 +4,18 @@ go 1.15
 
 require (
 	github.com/badoux/checkmail v1.2.1 // indirect
-	github.com/golang/protobuf v1.4.3
+	github.com/golang/protobuf v1.5.2
 	github.com/joho/godotenv v1.3.0
+	github.com/nats-io/nats.go v1.11.0
 	github.com/satori/go.uuid v1.2.0
 	github.com/softcorp-io/hqs_proto v0.0.42
 	go.mongodb.org/mongo-driver v1.4.4
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
 	go.uber.org/zap v1.16.0
-	google.golang.org/grpc v1.34.1
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013
+	google.golang.org/grpc v1.41.0
+	google.golang.org/protobuf v1.27.1
 )
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// AdminServiceName - full name of the administrative gRPC service. It lives
+// next to the generated privilege service and uses google.protobuf.Struct for
+// requests and responses, so it can grow without new generated code.
+const AdminServiceName = "hqs_privilege_service.PrivilegeAdminService"
+
+// AdminServiceServer - operations exposed by the administrative service.
+type AdminServiceServer interface {
+	Check(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	GetDeleted(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	Restore(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	Clone(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	Compare(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	AssignPrivilege(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	RevokePrivilege(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	GetEffectivePermissions(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	ListUsersWithPrivilege(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	CountUsersPerPrivilege(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	CreateGroup(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	UpdateGroup(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	GetGroup(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	GetAllGroups(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	DeleteGroup(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	AddGroupMember(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	RemoveGroupMember(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	LogLevel(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	ExportPrivileges(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	ImportPrivileges(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+}
+
+type adminFunc func(srv AdminServiceServer, ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+
+// adminMethod - builds the grpc method description for an admin operation.
+func adminMethod(name string, fn adminFunc) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(structpb.Struct)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return fn(srv.(AdminServiceServer), ctx, in)
+			}
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: "/" + AdminServiceName + "/" + name,
+			}
+			return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return fn(srv.(AdminServiceServer), ctx, req.(*structpb.Struct))
+			})
+		},
+	}
+}
+
+var adminServiceDesc = grpc.ServiceDesc{
+	ServiceName: AdminServiceName,
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		adminMethod("Check", AdminServiceServer.Check),
+		adminMethod("GetDeleted", AdminServiceServer.GetDeleted),
+		adminMethod("Restore", AdminServiceServer.Restore),
+		adminMethod("Clone", AdminServiceServer.Clone),
+		adminMethod("Compare", AdminServiceServer.Compare),
+		adminMethod("AssignPrivilege", AdminServiceServer.AssignPrivilege),
+		adminMethod("RevokePrivilege", AdminServiceServer.RevokePrivilege),
+		adminMethod("GetEffectivePermissions", AdminServiceServer.GetEffectivePermissions),
+		adminMethod("ListUsersWithPrivilege", AdminServiceServer.ListUsersWithPrivilege),
+		adminMethod("CountUsersPerPrivilege", AdminServiceServer.CountUsersPerPrivilege),
+		adminMethod("CreateGroup", AdminServiceServer.CreateGroup),
+		adminMethod("UpdateGroup", AdminServiceServer.UpdateGroup),
+		adminMethod("GetGroup", AdminServiceServer.GetGroup),
+		adminMethod("GetAllGroups", AdminServiceServer.GetAllGroups),
+		adminMethod("DeleteGroup", AdminServiceServer.DeleteGroup),
+		adminMethod("AddGroupMember", AdminServiceServer.AddGroupMember),
+		adminMethod("RemoveGroupMember", AdminServiceServer.RemoveGroupMember),
+		adminMethod("LogLevel", AdminServiceServer.LogLevel),
+		adminMethod("ExportPrivileges", AdminServiceServer.ExportPrivileges),
+		adminMethod("ImportPrivileges", AdminServiceServer.ImportPrivileges),
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterAdminServiceServer - registers the administrative service.
+func RegisterAdminServiceServer(s *grpc.Server, srv AdminServiceServer) {
+	s.RegisterService(&adminServiceDesc, srv)
+}
+
+// stringField - reads a string field from a request.
+func stringField(req *structpb.Struct, name string) string {
+	return req.GetFields()[name].GetStringValue()
+}
+
+// numberField - reads a number field from a request.
+func numberField(req *structpb.Struct, name string) int64 {
+	return int64(req.GetFields()[name].GetNumberValue())
+}
+
+// fromStruct - converts a request into a json serializable value.
+func fromStruct(req *structpb.Struct, v interface{}) error {
+	data, err := json.Marshal(req.AsMap())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// boolField - reads a bool field from a request.
+func boolField(req *structpb.Struct, name string) bool {
+	return req.GetFields()[name].GetBoolValue()
+}
+
+// toStruct - converts a json serializable value into a response.
+func toStruct(v interface{}) (*structpb.Struct, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	return structpb.NewStruct(fields)
+}
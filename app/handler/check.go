@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Check - reports inconsistencies between users, groups and privileges, and
+// repairs them with repair
+func (s *Handler) Check(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx)
+	log.Info("Request received")
+	report, err := s.repository.Check(ctx, boolField(req, "repair"))
+	if err != nil {
+		log.Error("Could not check privileges", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return toStruct(report)
+}
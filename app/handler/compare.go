@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Clone - copies the privilege with id into a new privilege called name
+func (s *Handler) Clone(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx, zap.String("privilege_id", stringField(req, "id")))
+	log.Info("Request received")
+	privilege, err := s.repository.Clone(ctx, stringField(req, "id"), stringField(req, "name"))
+	if err != nil {
+		log.Error("Could not clone privilege", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return toStruct(map[string]interface{}{
+		"privilege": privilege,
+	})
+}
+
+// Compare - compares the privileges with a_id and b_id permission by permission
+func (s *Handler) Compare(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx, zap.String("privilege_id", stringField(req, "a_id")), zap.String("other_privilege_id", stringField(req, "b_id")))
+	log.Info("Request received")
+	comparison, err := s.repository.Compare(ctx, stringField(req, "a_id"), stringField(req, "b_id"))
+	if err != nil {
+		log.Error("Could not compare privileges", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return toStruct(comparison)
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain - domain of the ErrorInfo attached to every domain error.
+const ErrorDomain = "privileges.hqs.softcorp.io"
+
+// ErrorInterceptor - turns the errors returned by a call into grpc status
+// errors. It runs outermost, so errors of the other interceptors pass
+// through it as well.
+func ErrorInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return nil, StatusError(err)
+	}
+	return resp, nil
+}
+
+// StreamErrorInterceptor - the streaming counterpart of ErrorInterceptor.
+func StreamErrorInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := handler(srv, stream); err != nil {
+		return StatusError(err)
+	}
+	return nil
+}
+
+// StatusError - the grpc status error for err. Repository errors get the code
+// of their kind, an ErrorInfo carrying their reason and, for validation
+// errors, a BadRequest naming the offending field. Messages are left as they
+// are. Errors that already are status errors are returned untouched.
+func StatusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+
+	repoErr := repository.AsError(err)
+	if repoErr == nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return status.Error(codes.DeadlineExceeded, err.Error())
+		}
+		return status.Error(codes.Unknown, err.Error())
+	}
+
+	st := status.New(code(repoErr.Kind), repoErr.Error())
+	withInfo, detailErr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   repoErr.Reason,
+		Domain:   ErrorDomain,
+		Metadata: map[string]string{"kind": repoErr.Kind.String()},
+	})
+	if detailErr != nil {
+		return st.Err()
+	}
+	st = withInfo
+
+	switch repoErr.Kind {
+	case repository.KindValidation:
+		st, detailErr = st.WithDetails(&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{{
+				Field:       repoErr.Field,
+				Description: repoErr.Error(),
+			}},
+		})
+	case repository.KindConflict, repository.KindProtected:
+		st, detailErr = st.WithDetails(&errdetails.PreconditionFailure{
+			Violations: []*errdetails.PreconditionFailure_Violation{{
+				Type:        repoErr.Kind.String(),
+				Subject:     repoErr.Reason,
+				Description: repoErr.Error(),
+			}},
+		})
+	}
+	if detailErr != nil {
+		return withInfo.Err()
+	}
+
+	return st.Err()
+}
+
+// code - the grpc code for a kind of repository error.
+func code(kind repository.Kind) codes.Code {
+	switch kind {
+	case repository.KindNotFound:
+		return codes.NotFound
+	case repository.KindValidation:
+		return codes.InvalidArgument
+	case repository.KindConflict, repository.KindProtected:
+		return codes.FailedPrecondition
+	case repository.KindPermissionDenied:
+		return codes.PermissionDenied
+	case repository.KindUnavailable:
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatusError(t *testing.T) {
+	existing := status.Error(codes.Aborted, "aborted")
+
+	tests := []struct {
+		name    string
+		err     error
+		code    codes.Code
+		message string
+		// reason and kind of the ErrorInfo, none when reason is empty
+		reason string
+		kind   string
+		// field of the BadRequest and subject of the PreconditionFailure, if
+		// expected
+		field   string
+		subject string
+	}{
+		{"internal", &repository.Error{Kind: repository.KindInternal, Reason: "BROKEN", Message: "broken"}, codes.Internal, "broken", "BROKEN", "internal", "", ""},
+		{"not found", &repository.Error{Kind: repository.KindNotFound, Reason: "PRIVILEGE_NOT_FOUND", Err: mongo.ErrNoDocuments}, codes.NotFound, mongo.ErrNoDocuments.Error(), "PRIVILEGE_NOT_FOUND", "not_found", "", ""},
+		{"validation", &repository.Error{Kind: repository.KindValidation, Reason: "INVALID_NAME", Field: "name", Message: "Name is required"}, codes.InvalidArgument, "Name is required", "INVALID_NAME", "validation", "name", ""},
+		{"conflict", &repository.Error{Kind: repository.KindConflict, Reason: "LOCKOUT", Message: "Cannot delete"}, codes.FailedPrecondition, "Cannot delete", "LOCKOUT", "conflict", "", "LOCKOUT"},
+		{"protected", &repository.Error{Kind: repository.KindProtected, Reason: "ROOT_PRIVILEGE", Message: "Cannot change root"}, codes.FailedPrecondition, "Cannot change root", "ROOT_PRIVILEGE", "protected", "", "ROOT_PRIVILEGE"},
+		{"permission denied", &repository.Error{Kind: repository.KindPermissionDenied, Reason: "BREAK_GLASS_USED", Message: "Used"}, codes.PermissionDenied, "Used", "BREAK_GLASS_USED", "permission_denied", "", ""},
+		{"unavailable", &repository.Error{Kind: repository.KindUnavailable, Reason: "DATABASE_UNAVAILABLE", Message: "down"}, codes.Unavailable, "down", "DATABASE_UNAVAILABLE", "unavailable", "", ""},
+		{"wrapped", fmt.Errorf("lookup: %w", &repository.Error{Kind: repository.KindConflict, Reason: "PRIVILEGE_DELETED", Message: "In the trash"}), codes.FailedPrecondition, "In the trash", "PRIVILEGE_DELETED", "conflict", "", "PRIVILEGE_DELETED"},
+		{"missing document", mongo.ErrNoDocuments, codes.NotFound, mongo.ErrNoDocuments.Error(), "NOT_FOUND", "not_found", "", ""},
+		{"status error", existing, codes.Aborted, "aborted", "", "", "", ""},
+		{"canceled", context.Canceled, codes.Canceled, context.Canceled.Error(), "", "", "", ""},
+		{"deadline exceeded", context.DeadlineExceeded, codes.DeadlineExceeded, context.DeadlineExceeded.Error(), "", "", "", ""},
+		{"plain error", errors.New("failed"), codes.Unknown, "failed", "", "", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			st := status.Convert(StatusError(test.err))
+			if st.Code() != test.code {
+				t.Fatalf("expected %v, got %v", test.code, st.Code())
+			}
+			if st.Message() != test.message {
+				t.Fatalf("expected message %q, got %q", test.message, st.Message())
+			}
+
+			var info *errdetails.ErrorInfo
+			var badRequest *errdetails.BadRequest
+			var precondition *errdetails.PreconditionFailure
+			for _, detail := range st.Details() {
+				switch detail := detail.(type) {
+				case *errdetails.ErrorInfo:
+					info = detail
+				case *errdetails.BadRequest:
+					badRequest = detail
+				case *errdetails.PreconditionFailure:
+					precondition = detail
+				default:
+					t.Fatalf("unexpected detail %v", detail)
+				}
+			}
+
+			if test.reason == "" {
+				if len(st.Details()) != 0 {
+					t.Fatalf("expected no details, got %v", st.Details())
+				}
+				return
+			}
+			if info == nil || info.Reason != test.reason || info.Domain != ErrorDomain || info.Metadata["kind"] != test.kind {
+				t.Fatalf("expected error info with reason %s and kind %s, got %v", test.reason, test.kind, info)
+			}
+			if test.field == "" && badRequest != nil {
+				t.Fatalf("expected no bad request, got %v", badRequest)
+			}
+			if test.field != "" && (badRequest == nil || len(badRequest.FieldViolations) != 1 || badRequest.FieldViolations[0].Field != test.field) {
+				t.Fatalf("expected a bad request on %s, got %v", test.field, badRequest)
+			}
+			if test.subject == "" && precondition != nil {
+				t.Fatalf("expected no precondition failure, got %v", precondition)
+			}
+			if test.subject != "" && (precondition == nil || len(precondition.Violations) != 1 || precondition.Violations[0].Subject != test.subject) {
+				t.Fatalf("expected a precondition failure on %s, got %v", test.subject, precondition)
+			}
+		})
+	}
+}
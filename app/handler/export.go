@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"context"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ExportPrivileges - returns every privilege and group outside the trash
+func (s *Handler) ExportPrivileges(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx)
+	log.Info("Request received")
+	data, err := s.repository.Export(ctx)
+	if err != nil {
+		log.Error("Could not export privileges", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return toStruct(data)
+}
+
+// ImportPrivileges - creates or replaces the privileges and groups given, as
+// returned by ExportPrivileges
+func (s *Handler) ImportPrivileges(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx)
+	log.Info("Request received")
+	data := &repository.Export{}
+	if err := fromStruct(req, data); err != nil {
+		log.Error("Could not read import", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	result, err := s.repository.Import(ctx, data, forceHelper(ctx))
+	if err != nil {
+		log.Error("Could not import privileges", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return toStruct(result)
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// CreateGroup - creates a new group from name, members and privilege_ids
+func (s *Handler) CreateGroup(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx)
+	log.Info("Request received")
+	group := &repository.Group{}
+	if err := fromStruct(req, group); err != nil {
+		log.Error("Could not read group", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	if err := s.repository.CreateGroup(ctx, group); err != nil {
+		log.Error("Could not create group", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return toStruct(map[string]interface{}{
+		"group": group,
+	})
+}
+
+// UpdateGroup - updates the name and privilege_ids of an existing group
+func (s *Handler) UpdateGroup(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx)
+	log.Info("Request received")
+	group := &repository.Group{}
+	if err := fromStruct(req, group); err != nil {
+		log.Error("Could not read group", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+	log = s.log(ctx, zap.String("group_id", group.ID))
+
+	if err := s.repository.UpdateGroup(ctx, group, forceHelper(ctx)); err != nil {
+		log.Error("Could not update group", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return &structpb.Struct{}, nil
+}
+
+// GetGroup - gets a group by its id
+func (s *Handler) GetGroup(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx, zap.String("group_id", stringField(req, "id")))
+	log.Info("Request received")
+	group, err := s.repository.GetGroup(ctx, stringField(req, "id"))
+	if err != nil {
+		log.Error("Could not get group", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return toStruct(map[string]interface{}{
+		"group": group,
+	})
+}
+
+// GetAllGroups - get all groups
+func (s *Handler) GetAllGroups(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx)
+	log.Info("Request received")
+	groups, err := s.repository.GetAllGroups(ctx)
+	if err != nil {
+		log.Error("Could not get all groups", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return toStruct(map[string]interface{}{
+		"groups": groups,
+	})
+}
+
+// DeleteGroup - deletes a group
+func (s *Handler) DeleteGroup(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx, zap.String("group_id", stringField(req, "id")))
+	log.Info("Request received")
+	if err := s.repository.DeleteGroup(ctx, stringField(req, "id"), forceHelper(ctx)); err != nil {
+		log.Error("Could not delete group", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return &structpb.Struct{}, nil
+}
+
+// AddGroupMember - adds user_id to the group with group_id
+func (s *Handler) AddGroupMember(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx, zap.String("group_id", stringField(req, "group_id")), zap.String("user_id", stringField(req, "user_id")))
+	log.Info("Request received")
+	if err := s.repository.AddGroupMember(ctx, stringField(req, "group_id"), stringField(req, "user_id")); err != nil {
+		log.Error("Could not add group member", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return &structpb.Struct{}, nil
+}
+
+// RemoveGroupMember - removes user_id from the group with group_id
+func (s *Handler) RemoveGroupMember(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx, zap.String("group_id", stringField(req, "group_id")), zap.String("user_id", stringField(req, "user_id")))
+	log.Info("Request received")
+	if err := s.repository.RemoveGroupMember(ctx, stringField(req, "group_id"), stringField(req, "user_id"), forceHelper(ctx)); err != nil {
+		log.Error("Could not remove group member", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return &structpb.Struct{}, nil
+}
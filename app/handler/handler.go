@@ -2,47 +2,48 @@ package handler
 
 import (
 	"context"
-	"errors"
-	"fmt"
-	"os"
 	"strings"
 
 	"go.uber.org/zap"
-	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 
+	logging "github.com/softcorp-io/hqs-privileges-service/logging"
 	repository "github.com/softcorp-io/hqs-privileges-service/repository"
 	privilegeProto "github.com/softcorp-io/hqs_proto/go_hqs/hqs_privilege_service"
-	userProto "github.com/softcorp-io/hqs_proto/go_hqs/hqs_user_service"
 )
 
 // Handler - struct used through program and passed to go-micro.
 type Handler struct {
 	repository repository.Repository
 	zapLog     *zap.Logger
+	level      zap.AtomicLevel
 }
 
-// NewHandler returns a Handler object
-func NewHandler(repo repository.Repository, zapLog *zap.Logger) *Handler {
-	return &Handler{repo, zapLog}
+// NewHandler returns a Handler object. Callers are authenticated and
+// authorized by the interceptors in auth before a method is called. The
+// LogLevel admin operation changes level.
+func NewHandler(repo repository.Repository, zapLog *zap.Logger, level zap.AtomicLevel) *Handler {
+	return &Handler{repo, zapLog, level}
+}
+
+// log - the logger of the call in ctx, after adding fields to it.
+func (s *Handler) log(ctx context.Context, fields ...zap.Field) *zap.Logger {
+	logging.Annotate(ctx, fields...)
+	return logging.FromContext(ctx, s.zapLog)
 }
 
 // Ping - used for other service to check if live
 func (s *Handler) Ping(ctx context.Context, req *privilegeProto.Request) (*privilegeProto.Response, error) {
-	s.zapLog.Info("Recieved new request")
+	s.log(ctx).Info("Request received")
 	return &privilegeProto.Response{}, nil
 }
 
 // Create - creates a new privilege and stores it in the database
 func (s *Handler) Create(ctx context.Context, req *privilegeProto.Privilege) (*privilegeProto.Response, error) {
-	s.zapLog.Info("Recieved new request")
-	if err := s.validateTokenHelper(ctx); err != nil {
-		s.zapLog.Error(fmt.Sprintf("Could not validate user with err %v", err))
-		return &privilegeProto.Response{}, err
-	}
-
+	log := s.log(ctx, zap.String("privilege_name", req.Name))
+	log.Info("Request received")
 	if err := s.repository.Create(ctx, repository.MarshalPrivilege(req)); err != nil {
-		s.zapLog.Error(fmt.Sprintf("Could not create privilege with err %v", err))
+		log.Error("Could not create privilege", zap.Error(err))
 		return &privilegeProto.Response{}, err
 	}
 	return &privilegeProto.Response{}, nil
@@ -50,14 +51,10 @@ func (s *Handler) Create(ctx context.Context, req *privilegeProto.Privilege) (*p
 
 // Update - updates an existing privilege
 func (s *Handler) Update(ctx context.Context, req *privilegeProto.Privilege) (*privilegeProto.Response, error) {
-	s.zapLog.Info("Recieved new request")
-	if err := s.validateTokenHelper(ctx); err != nil {
-		s.zapLog.Error(fmt.Sprintf("Could not validate user with err %v", err))
-		return &privilegeProto.Response{}, err
-	}
-
-	if err := s.repository.Update(ctx, repository.MarshalPrivilege(req)); err != nil {
-		s.zapLog.Error(fmt.Sprintf("Could not update privilege with err %v", err))
+	log := s.log(ctx, zap.String("privilege_id", req.Id))
+	log.Info("Request received")
+	if err := s.repository.Update(ctx, repository.MarshalPrivilege(req), forceHelper(ctx)); err != nil {
+		log.Error("Could not update privilege", zap.Error(err))
 		return &privilegeProto.Response{}, err
 	}
 	return &privilegeProto.Response{}, nil
@@ -65,9 +62,10 @@ func (s *Handler) Update(ctx context.Context, req *privilegeProto.Privilege) (*p
 
 // Get - gets a privilege by its id
 func (s *Handler) Get(ctx context.Context, req *privilegeProto.Privilege) (*privilegeProto.Response, error) {
+	log := s.log(ctx, zap.String("privilege_id", req.Id))
 	privilege, err := s.repository.Get(ctx, repository.MarshalPrivilege(req))
 	if err != nil {
-		s.zapLog.Error(fmt.Sprintf("Could not get privilege with err %v", err))
+		log.Error("Could not get privilege", zap.Error(err))
 		return &privilegeProto.Response{}, err
 	}
 
@@ -81,7 +79,7 @@ func (s *Handler) Get(ctx context.Context, req *privilegeProto.Privilege) (*priv
 func (s *Handler) GetRoot(ctx context.Context, req *privilegeProto.Request) (*privilegeProto.Response, error) {
 	privilege, err := s.repository.GetRoot(ctx)
 	if err != nil {
-		s.zapLog.Error(fmt.Sprintf("Could not get root privilege with err %v", err))
+		s.log(ctx).Error("Could not get root privilege", zap.Error(err))
 		return &privilegeProto.Response{}, err
 	}
 
@@ -95,7 +93,7 @@ func (s *Handler) GetRoot(ctx context.Context, req *privilegeProto.Request) (*pr
 func (s *Handler) GetDefault(ctx context.Context, req *privilegeProto.Request) (*privilegeProto.Response, error) {
 	privilege, err := s.repository.GetDefault(ctx)
 	if err != nil {
-		s.zapLog.Error(fmt.Sprintf("Could not get default privilege with err %v", err))
+		s.log(ctx).Error("Could not get default privilege", zap.Error(err))
 		return &privilegeProto.Response{}, err
 	}
 
@@ -109,7 +107,7 @@ func (s *Handler) GetDefault(ctx context.Context, req *privilegeProto.Request) (
 func (s *Handler) GetAll(ctx context.Context, req *privilegeProto.Request) (*privilegeProto.Response, error) {
 	privileges, err := s.repository.GetAll(ctx)
 	if err != nil {
-		s.zapLog.Error(fmt.Sprintf("Could not get all privileges with err %v", err))
+		s.log(ctx).Error("Could not get all privileges", zap.Error(err))
 		return &privilegeProto.Response{}, err
 	}
 
@@ -121,79 +119,28 @@ func (s *Handler) GetAll(ctx context.Context, req *privilegeProto.Request) (*pri
 
 // Delete - deltes a privilege
 func (s *Handler) Delete(ctx context.Context, req *privilegeProto.Privilege) (*privilegeProto.Response, error) {
-	s.zapLog.Info("Recieved new request")
-	if err := s.validateTokenHelper(ctx); err != nil {
-		s.zapLog.Error(fmt.Sprintf("Could not validate user with err %v", err))
-		return &privilegeProto.Response{}, err
-	}
-
-	if err := s.repository.Delete(ctx, repository.MarshalPrivilege(req)); err != nil {
-		s.zapLog.Error(fmt.Sprintf("Could not get privilege with err %v", err))
+	log := s.log(ctx, zap.String("privilege_id", req.Id))
+	log.Info("Request received")
+	if err := s.repository.Delete(ctx, repository.MarshalPrivilege(req), forceHelper(ctx)); err != nil {
+		log.Error("Could not delete privilege", zap.Error(err))
 		return &privilegeProto.Response{}, err
 	}
 
 	return &privilegeProto.Response{}, nil
 }
 
-// s.validateTokenHelper - helper function to validate tokens inside functions in Handler
-func (s *Handler) validateTokenHelper(ctx context.Context) error {
+// forceHelper - reports whether the caller asked to override safety checks
+// with the force header.
+func forceHelper(ctx context.Context) bool {
 	meta, ok := metadata.FromIncomingContext(ctx)
-
 	if !ok {
-		s.zapLog.Error("Could not validate token")
-		return errors.New("Could not validate token")
-	}
-
-	token := meta["token"]
-
-	if len(token) == 0 {
-		s.zapLog.Error("Missing token header in context")
-		return errors.New("Missing token header in context")
+		return false
 	}
 
-	if strings.Trim(token[0], " ") == "" {
-		s.zapLog.Error("Token is empty")
-		return errors.New("Token is empty")
-	}
-
-	userToken := &userProto.Token{
-		Token: token[0],
-	}
-
-	// setup user client
-	ip, check := os.LookupEnv("USER_SERVICE_IP")
-	if !check {
-		s.zapLog.Error("Required USER_SERVICE_IP")
-		return errors.New("Required USER_SERVICE_IP")
-	}
-	port, check := os.LookupEnv("USER_SERVICE_PORT")
-	if !check {
-		s.zapLog.Error("Required USER_SERVICE_PORT")
-		return errors.New("Required USER_SERVICE_PORT")
-	}
-
-	conn, err := grpc.DialContext(context.Background(), ip+":"+port, grpc.WithInsecure())
-	if err != nil {
-		s.zapLog.Error(fmt.Sprintf("Could not dial user service with err %v", err))
-		return err
-	}
-	defer conn.Close()
-	userClient := userProto.NewUserServiceClient(conn)
-
-	_, err = userClient.Ping(context.Background(), &userProto.Request{})
-	if err != nil {
-		s.zapLog.Error(fmt.Sprintf("Could not ping user service with err %v", err))
-		return err
-	}
-
-	// validate token
-	resultToken, err := userClient.ValidateToken(context.Background(), userToken)
-	if err != nil {
-		return err
-	}
-	if resultToken.ManagePrivileges == false {
-		return errors.New("User not allowed to manage privileges")
+	force := meta["force"]
+	if len(force) == 0 {
+		return false
 	}
 
-	return nil
+	return strings.ToLower(strings.Trim(force[0], " ")) == "true"
 }
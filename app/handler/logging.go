@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	alert "github.com/softcorp-io/hqs-privileges-service/alert"
+	auth "github.com/softcorp-io/hqs-privileges-service/auth"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// LogLevel - returns the log level of the service, after changing it to level
+// if one is given. Security alerts are written whatever the level.
+func (s *Handler) LogLevel(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	s.log(ctx).Info("Request received")
+	if name := stringField(req, "level"); name != "" {
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(name)); err != nil {
+			return &structpb.Struct{}, status.Error(codes.InvalidArgument, fmt.Sprintf("Unknown log level %s", name))
+		}
+
+		// recorded as an alert, as a raised level hides what happens next
+		caller := ""
+		if identity, ok := auth.FromContext(ctx); ok {
+			caller = identity.UserID
+		}
+		alert.NewLogger().Warn("Log level changed",
+			zap.String("from", s.level.Level().String()),
+			zap.String("to", level.String()),
+			zap.String("caller_id", caller),
+		)
+		s.level.SetLevel(level)
+	}
+
+	return toStruct(map[string]interface{}{
+		"level": s.level.Level().String(),
+	})
+}
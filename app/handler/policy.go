@@ -0,0 +1,39 @@
+package handler
+
+import (
+	auth "github.com/softcorp-io/hqs-privileges-service/auth"
+)
+
+// PrivilegeServiceName - full name of the generated privilege gRPC service.
+const PrivilegeServiceName = "hqs_privilege_service.PrivilegeService"
+
+// Policy - what callers need for every method served. Methods missing here
+// are denied, so a new RPC must be added before it can be called.
+func Policy() auth.Policy {
+	policy := auth.Policy{
+		"/grpc.health.v1.Health/Check":                                   auth.Public,
+		"/grpc.health.v1.Health/Watch":                                   auth.Public,
+		"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo": auth.Public,
+	}
+
+	privilegeMethods := map[string]auth.Requirement{
+		"Ping":       auth.Public,
+		"Get":        auth.Authenticated,
+		"GetAll":     auth.Authenticated,
+		"GetRoot":    auth.Authenticated,
+		"GetDefault": auth.Authenticated,
+		"Create":     auth.ManagePrivileges,
+		"Update":     auth.ManagePrivileges,
+		"Delete":     auth.ManagePrivileges,
+	}
+	for name, requirement := range privilegeMethods {
+		policy["/"+PrivilegeServiceName+"/"+name] = requirement
+	}
+
+	// every administrative operation needs the ability to manage privileges
+	for _, method := range adminServiceDesc.Methods {
+		policy["/"+AdminServiceName+"/"+method.MethodName] = auth.ManagePrivileges
+	}
+
+	return policy
+}
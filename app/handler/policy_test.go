@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"testing"
+
+	auth "github.com/softcorp-io/hqs-privileges-service/auth"
+	privilegeProto "github.com/softcorp-io/hqs_proto/go_hqs/hqs_privilege_service"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+func TestPolicyCoversEveryMethod(t *testing.T) {
+	srv := grpc.NewServer()
+	handle := NewHandler(nil, zap.NewNop(), zap.NewAtomicLevel())
+	privilegeProto.RegisterPrivilegeServiceServer(srv, handle)
+	RegisterAdminServiceServer(srv, handle)
+	services := srv.GetServiceInfo()
+	policy := Policy()
+
+	for _, service := range []string{PrivilegeServiceName, AdminServiceName} {
+		info, ok := services[service]
+		if !ok || len(info.Methods) == 0 {
+			t.Fatalf("expected %s to be registered with methods", service)
+		}
+		for _, method := range info.Methods {
+			fullMethod := "/" + service + "/" + method.Name
+			if policy.Requirement(fullMethod) == auth.Deny {
+				t.Errorf("expected %s to be in the policy", fullMethod)
+			}
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		method      string
+		requirement auth.Requirement
+	}{
+		{"/grpc.health.v1.Health/Check", auth.Public},
+		{"/" + PrivilegeServiceName + "/Ping", auth.Public},
+		{"/" + PrivilegeServiceName + "/Get", auth.Authenticated},
+		{"/" + PrivilegeServiceName + "/GetAll", auth.Authenticated},
+		{"/" + PrivilegeServiceName + "/Create", auth.ManagePrivileges},
+		{"/" + PrivilegeServiceName + "/Update", auth.ManagePrivileges},
+		{"/" + PrivilegeServiceName + "/Delete", auth.ManagePrivileges},
+		{"/" + PrivilegeServiceName + "/Unknown", auth.Deny},
+		{"/" + AdminServiceName + "/Unknown", auth.Deny},
+		{"/other.Service/Get", auth.Deny},
+		{"Get", auth.Deny},
+	}
+
+	policy := Policy()
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			if requirement := policy.Requirement(test.method); requirement != test.requirement {
+				t.Fatalf("expected %v, got %v", test.requirement, requirement)
+			}
+		})
+	}
+
+	// every administrative operation needs the ability to manage privileges
+	for _, method := range adminServiceDesc.Methods {
+		fullMethod := "/" + AdminServiceName + "/" + method.MethodName
+		if requirement := policy.Requirement(fullMethod); requirement != auth.ManagePrivileges {
+			t.Errorf("expected %s to need %v, got %v", fullMethod, auth.ManagePrivileges, requirement)
+		}
+	}
+}
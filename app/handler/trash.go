@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// GetDeleted - get all privileges in the trash
+func (s *Handler) GetDeleted(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx)
+	log.Info("Request received")
+	privileges, err := s.repository.GetDeleted(ctx)
+	if err != nil {
+		log.Error("Could not get deleted privileges", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return toStruct(map[string]interface{}{
+		"privileges": privileges,
+	})
+}
+
+// Restore - restores the privilege with id from the trash, and with
+// restore_holders gives it back to the users and groups that lost it
+func (s *Handler) Restore(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx, zap.String("privilege_id", stringField(req, "id")))
+	log.Info("Request received")
+	privilege, err := s.repository.Restore(ctx, stringField(req, "id"), boolField(req, "restore_holders"))
+	if err != nil {
+		log.Error("Could not restore privilege", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return toStruct(map[string]interface{}{
+		"privilege": privilege,
+	})
+}
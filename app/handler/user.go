@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// AssignPrivilege - adds the privilege with privilege_id to the user with user_id
+func (s *Handler) AssignPrivilege(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx, zap.String("user_id", stringField(req, "user_id")), zap.String("privilege_id", stringField(req, "privilege_id")))
+	log.Info("Request received")
+	if err := s.repository.AssignPrivilege(ctx, stringField(req, "user_id"), stringField(req, "privilege_id")); err != nil {
+		log.Error("Could not assign privilege", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return &structpb.Struct{}, nil
+}
+
+// RevokePrivilege - removes the privilege with privilege_id from the user with user_id
+func (s *Handler) RevokePrivilege(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx, zap.String("user_id", stringField(req, "user_id")), zap.String("privilege_id", stringField(req, "privilege_id")))
+	log.Info("Request received")
+	if err := s.repository.RevokePrivilege(ctx, stringField(req, "user_id"), stringField(req, "privilege_id"), forceHelper(ctx)); err != nil {
+		log.Error("Could not revoke privilege", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return &structpb.Struct{}, nil
+}
+
+// GetEffectivePermissions - resolves the permissions of user_id, directly and through groups
+func (s *Handler) GetEffectivePermissions(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx, zap.String("user_id", stringField(req, "user_id")))
+	log.Info("Request received")
+	effective, err := s.repository.GetEffectivePermissions(ctx, stringField(req, "user_id"))
+	if err != nil {
+		log.Error("Could not get effective permissions", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return toStruct(effective)
+}
+
+// ListUsersWithPrivilege - lists a page of users holding privilege_id
+func (s *Handler) ListUsersWithPrivilege(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx, zap.String("privilege_id", stringField(req, "privilege_id")))
+	log.Info("Request received")
+	users, total, err := s.repository.ListUsersWithPrivilege(ctx, stringField(req, "privilege_id"), numberField(req, "offset"), numberField(req, "limit"))
+	if err != nil {
+		log.Error("Could not list users", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return toStruct(map[string]interface{}{
+		"users": users,
+		"total": total,
+	})
+}
+
+// CountUsersPerPrivilege - counts the users holding each privilege
+func (s *Handler) CountUsersPerPrivilege(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	log := s.log(ctx)
+	log.Info("Request received")
+	counts, err := s.repository.CountUsersPerPrivilege(ctx)
+	if err != nil {
+		log.Error("Could not count users", zap.Error(err))
+		return &structpb.Struct{}, err
+	}
+
+	return toStruct(map[string]interface{}{
+		"privileges": counts,
+	})
+}
@@ -0,0 +1,171 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Services reported by the health server besides the grpc services.
+const (
+	// LivenessService - serving for as long as the process is able to answer.
+	LivenessService = "liveness"
+	// ReadinessService - serving only when every dependency is healthy.
+	ReadinessService = "readiness"
+)
+
+// checkTimeout - how long a single dependency check may take.
+const checkTimeout = 2 * time.Second
+
+// Check - probes a single dependency, returning nil if it is healthy.
+type Check func(ctx context.Context) error
+
+// Checker - runs dependency checks and reports them through the standard grpc
+// health service. Each dependency is reported as its own service, readiness
+// and the services in serving are reported serving only when all of them are.
+type Checker struct {
+	server   *health.Server
+	zapLog   *zap.Logger
+	services []string
+	shutdown chan struct{}
+	once     sync.Once
+
+	mu       sync.Mutex
+	checks   map[string]Check
+	draining bool
+}
+
+// NewChecker - returns a Checker reporting readiness for services.
+func NewChecker(zapLog *zap.Logger, services ...string) *Checker {
+	c := &Checker{
+		server:   health.NewServer(),
+		zapLog:   zapLog,
+		services: services,
+		shutdown: make(chan struct{}),
+		checks:   map[string]Check{},
+	}
+	c.server.SetServingStatus(LivenessService, healthpb.HealthCheckResponse_SERVING)
+	c.setReady(healthpb.HealthCheckResponse_NOT_SERVING)
+	return c
+}
+
+// Server - the grpc health server to register. Its watch streams end on
+// Shutdown.
+func (c *Checker) Server() healthpb.HealthServer {
+	return &watchServer{c.server, c.shutdown}
+}
+
+// Add - adds a dependency check reported under name.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+	c.server.SetServingStatus(name, healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+// Probe - runs every check once and updates the reported statuses.
+func (c *Checker) Probe(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ready := healthpb.HealthCheckResponse_SERVING
+	for name, check := range c.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := check(checkCtx)
+		cancel()
+
+		if err != nil {
+			c.zapLog.Warn(fmt.Sprintf("Health check %s failed with err %v", name, err))
+			c.server.SetServingStatus(name, healthpb.HealthCheckResponse_NOT_SERVING)
+			ready = healthpb.HealthCheckResponse_NOT_SERVING
+			continue
+		}
+		c.server.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+	if c.draining {
+		ready = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	c.setReady(ready)
+}
+
+// Run - probes the dependencies every interval until ctx is done.
+func (c *Checker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.Probe(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain - reports the service as not ready for good, so load balancers stop
+// sending new requests while in-flight ones finish. Liveness is left alone.
+func (c *Checker) Drain() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.draining = true
+	c.setReady(healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+// Shutdown - reports every service as not serving, liveness included, and
+// ends open watch streams so they do not hold up a graceful stop of the grpc
+// server. Statuses no longer change afterwards.
+func (c *Checker) Shutdown() {
+	c.once.Do(func() {
+		c.server.Shutdown()
+		close(c.shutdown)
+	})
+}
+
+func (c *Checker) setReady(status healthpb.HealthCheckResponse_ServingStatus) {
+	c.server.SetServingStatus("", status)
+	c.server.SetServingStatus(ReadinessService, status)
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, status)
+	}
+}
+
+// watchServer - a health server whose watch streams end once shutdown is
+// closed.
+type watchServer struct {
+	healthpb.HealthServer
+	shutdown <-chan struct{}
+}
+
+// Watch - watches like the health server until the client leaves or shutdown
+// is closed.
+func (s *watchServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return s.HealthServer.Watch(req, &watchStream{stream, ctx})
+}
+
+// watchStream - a watch stream with its context replaced.
+type watchStream struct {
+	healthpb.Health_WatchServer
+	ctx context.Context
+}
+
+// Context - the replaced context.
+func (s *watchStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// request - what is known about the call being handled, filled in as it goes
+// through the interceptors and the handler.
+type request struct {
+	logger *zap.Logger
+	mu     sync.Mutex
+	fields []zap.Field
+}
+
+type requestKey struct{}
+
+// newContext - returns ctx carrying a call logged with logger.
+func newContext(ctx context.Context, logger *zap.Logger) (context.Context, *request) {
+	r := &request{logger: logger}
+	return context.WithValue(ctx, requestKey{}, r), r
+}
+
+// Annotate - adds fields to every later log line of the call in ctx and to
+// the line written once it is handled. Without a call it does nothing.
+func Annotate(ctx context.Context, fields ...zap.Field) {
+	r, ok := ctx.Value(requestKey{}).(*request)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fields = append(r.fields, fields...)
+}
+
+// FromContext - a logger with the fields of the call in ctx, or fallback
+// outside of a call.
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	r, ok := ctx.Value(requestKey{}).(*request)
+	if !ok {
+		return fallback
+	}
+	return r.with()
+}
+
+// with - the logger of the call with every field added so far.
+func (r *request) with() *zap.Logger {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.logger.With(r.fields...)
+}
@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"context"
+	"time"
+	"unicode"
+
+	uuid "github.com/satori/go.uuid"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader - metadata key the request id is read from and sent back in.
+const RequestIDHeader = "x-request-id"
+
+// maxRequestIDLength - longest request id accepted from a caller.
+const maxRequestIDLength = 128
+
+// Interceptor - logs every call once it is handled, and gives the handler a
+// logger carrying the method, request id and trace of the call.
+type Interceptor struct {
+	zapLog *zap.Logger
+}
+
+// NewInterceptor - returns an interceptor logging to zapLog.
+func NewInterceptor(zapLog *zap.Logger) *Interceptor {
+	return &Interceptor{zapLog}
+}
+
+// UnaryInterceptor - logs unary calls. It should run outside the error
+// interceptor so it logs the code the caller gets.
+func (i *Interceptor) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, r := i.start(ctx, info.FullMethod)
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	r.done(start, err)
+	return resp, err
+}
+
+// StreamInterceptor - the streaming counterpart of UnaryInterceptor.
+func (i *Interceptor) StreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, r := i.start(stream.Context(), info.FullMethod)
+	start := time.Now()
+	err := handler(srv, &loggedStream{stream, ctx})
+	r.done(start, err)
+	return err
+}
+
+// loggedStream - a server stream carrying the logger of the call.
+type loggedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context - the context with the logger of the call.
+func (s *loggedStream) Context() context.Context {
+	return s.ctx
+}
+
+// start - returns ctx carrying the call to method, with the request id of the
+// caller or a new one, which is sent back to the caller.
+func (i *Interceptor) start(ctx context.Context, method string) (context.Context, *request) {
+	id := requestID(ctx)
+	// the gateway has no grpc transport to send headers on, the error says
+	// nothing else
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+
+	fields := []zap.Field{zap.String("method", method), zap.String("request_id", id)}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		fields = append(fields, zap.String("trace_id", span.TraceID().String()))
+	}
+	return newContext(ctx, i.zapLog.With(fields...))
+}
+
+// done - logs the call with how long it took and how it ended.
+func (r *request) done(start time.Time, err error) {
+	code := status.Code(err)
+	logger := r.with()
+	fields := []zap.Field{zap.Duration("duration", time.Since(start)), zap.String("code", code.String())}
+	if err != nil {
+		fields = append(fields, zap.String("error", status.Convert(err).Message()))
+	}
+
+	switch code {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss, codes.DeadlineExceeded:
+		logger.Error("Handled request", fields...)
+	default:
+		logger.Info("Handled request", fields...)
+	}
+}
+
+// requestID - the request id in the incoming metadata of ctx, see RequestID.
+func requestID(ctx context.Context) string {
+	sent := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDHeader); len(values) > 0 {
+			sent = values[0]
+		}
+	}
+	return RequestID(sent)
+}
+
+// RequestID - the request id a caller sent, if it is usable, or a new one.
+func RequestID(sent string) string {
+	if validRequestID(sent) {
+		return sent
+	}
+	return uuid.NewV4().String()
+}
+
+// validRequestID - short and printable, so it can not forge log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		name  string
+		id    string
+		valid bool
+	}{
+		{"uuid", "0b6e5d9c-5a3f-4f4e-9c1e-0f9f3b0c7a21", true},
+		{"empty", "", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"longest", strings.Repeat("a", maxRequestIDLength), true},
+		{"newline", "abc\n{\"level\":\"error\"}", false},
+		{"carriage return", "abc\rdef", false},
+		{"space", "abc def", false},
+		{"tab", "abc\tdef", false},
+		{"non ascii", "abcé", false},
+		{"non ascii space", "abc def", false},
+		{"control character", "abc\x1bdef", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if valid := validRequestID(test.id); valid != test.valid {
+				t.Fatalf("expected %v, got %v", test.valid, valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package logging
+
+import (
+	"os"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// New - the logger of the service, writing json to stderr at level with
+// secrets redacted. Changing level changes what every logger derived from it
+// writes.
+func New(level zap.AtomicLevel) *zap.Logger {
+	core := zapcore.NewCore(
+		zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+		zapcore.Lock(os.Stderr),
+		level,
+	)
+	return zap.New(Redact(core), zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+}
@@ -1,18 +1,16 @@
 package main
 
 import (
-	"sync"
+	"os"
 
-	server "github.com/softcorp-io/hqs-privileges-service/server"
+	cli "github.com/softcorp-io/hqs-privileges-service/cli"
+	logging "github.com/softcorp-io/hqs-privileges-service/logging"
 	"go.uber.org/zap"
 )
 
 func main() {
-	var wg sync.WaitGroup
-	logger, _ := zap.NewProduction()
+	level := zap.NewAtomicLevel()
+	logger := logging.New(level)
 
-	server.Init(logger)
-
-	wg.Add(1)
-	server.Run(logger, &wg)
+	os.Exit(cli.Run(logger, level, os.Args[1:]))
 }
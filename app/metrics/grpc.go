@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryInterceptor - counts calls by method and code and records how long
+// they took. It should run outermost so it sees the code the caller gets.
+func (m *Metrics) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	m.observeCall(info.FullMethod, start, err)
+	return resp, err
+}
+
+// StreamInterceptor - the streaming counterpart of UnaryInterceptor.
+func (m *Metrics) StreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, stream)
+	m.observeCall(info.FullMethod, start, err)
+	return err
+}
+
+func (m *Metrics) observeCall(method string, start time.Time, err error) {
+	m.rpcDuration.Observe(since(start), method)
+	m.rpcRequests.Inc(method, status.Code(err).String())
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Metrics - every metric the service exposes.
+type Metrics struct {
+	Registry *Registry
+
+	rpcRequests        *Counter
+	rpcDuration        *Histogram
+	mongoDuration      *Histogram
+	repositoryDuration *Histogram
+	validations        *Counter
+
+	// privileges are counted by WatchPrivileges rather than on scrape, so
+	// scrapes never wait on mongo
+	countsMu       sync.Mutex
+	privileges     []Sample
+	privilegeUsers []Sample
+}
+
+// New - registers the metrics of the service.
+func New() *Metrics {
+	r := NewRegistry()
+	m := &Metrics{Registry: r}
+
+	m.rpcRequests = r.NewCounter("hqs_grpc_requests_total", "Calls handled, by method and grpc code.", "method", "code")
+	m.rpcDuration = r.NewHistogram("hqs_grpc_request_duration_seconds", "How long calls took, by method.", DefaultBuckets, "method")
+	m.mongoDuration = r.NewHistogram("hqs_mongo_command_duration_seconds", "How long mongo commands took, by command and outcome.", DefaultBuckets, "command", "outcome")
+	m.repositoryDuration = r.NewHistogram("hqs_repository_operation_duration_seconds", "How long repository operations took, by operation and outcome.", DefaultBuckets, "operation", "outcome")
+	m.validations = r.NewCounter("hqs_user_service_validations_total", "Tokens validated with the user service, by outcome.", "outcome")
+
+	r.NewGaugeFunc("hqs_privileges", "Privileges, by state.", func() []Sample {
+		m.countsMu.Lock()
+		defer m.countsMu.Unlock()
+		return m.privileges
+	}, "state")
+	r.NewGaugeFunc("hqs_privilege_users", "Users holding each privilege directly.", func() []Sample {
+		m.countsMu.Lock()
+		defer m.countsMu.Unlock()
+		return m.privilegeUsers
+	}, "privilege_id", "privilege_name")
+
+	return m
+}
+
+// ListenAndServe - serves the metrics on port under /metrics until the server
+// is closed.
+func (m *Metrics) ListenAndServe(zapLog *zap.Logger, port string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Registry)
+	srv := &http.Server{Addr: fmt.Sprintf(":%s", port), Handler: mux}
+	go func() {
+		zapLog.Info(fmt.Sprintf("Metrics running on port: %s", port))
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			zapLog.Error(fmt.Sprintf("Metrics server failed with err %v", err))
+		}
+	}()
+	return srv
+}
+
+// since - seconds elapsed since start.
+func since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
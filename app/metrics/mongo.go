@@ -0,0 +1,21 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// CommandMonitor - a mongo command monitor recording how long every command
+// took and whether it failed.
+func (m *Metrics) CommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			m.mongoDuration.Observe(time.Duration(e.DurationNanos).Seconds(), e.CommandName, "ok")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			m.mongoDuration.Observe(time.Duration(e.DurationNanos).Seconds(), e.CommandName, "error")
+		},
+	}
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"go.uber.org/zap"
+)
+
+// countTimeout - how long counting privileges and their users may take.
+const countTimeout = 30 * time.Second
+
+// WatchPrivileges - counts privileges and the users holding each of them
+// every interval until ctx is done.
+func (m *Metrics) WatchPrivileges(ctx context.Context, zapLog *zap.Logger, repo repository.Repository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		countCtx, cancel := context.WithTimeout(ctx, countTimeout)
+		if err := m.countPrivileges(countCtx, repo); err != nil && ctx.Err() == nil {
+			zapLog.Error(fmt.Sprintf("Could not count privileges for metrics with err %v", err))
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Metrics) countPrivileges(ctx context.Context, repo repository.Repository) error {
+	counts, err := repo.CountUsersPerPrivilege(ctx)
+	if err != nil {
+		return err
+	}
+	deleted, err := repo.GetDeleted(ctx)
+	if err != nil {
+		return err
+	}
+
+	users := []Sample{}
+	for _, count := range counts {
+		users = append(users, Sample{[]string{count.PrivilegeID, count.Name}, float64(count.Users)})
+	}
+	privileges := []Sample{
+		{[]string{"active"}, float64(len(counts))},
+		{[]string{"deleted"}, float64(len(deleted))},
+	}
+
+	m.countsMu.Lock()
+	m.privileges, m.privilegeUsers = privileges, users
+	m.countsMu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,259 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets - upper bounds in seconds of the latency histograms.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Sample - a single value of a metric with the values of its labels.
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// collector - a metric written in the prometheus text format.
+type collector interface {
+	write(buf *bytes.Buffer)
+}
+
+// Registry - the metrics served on the metrics endpoint.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry - returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// ServeHTTP - writes every metric in the prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	collectors := append([]collector{}, r.collectors...)
+	r.mu.Unlock()
+
+	buf := &bytes.Buffer{}
+	for _, c := range collectors {
+		c.write(buf)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// desc - name, help and label names shared by every kind of metric.
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d *desc) header(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.kind)
+}
+
+// line - writes one sample of the metric called name.
+func (d *desc) line(buf *bytes.Buffer, name string, values []string, extra string, value float64) {
+	buf.WriteString(name)
+	pairs := []string{}
+	for i, label := range d.labels {
+		if i < len(values) {
+			pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", label, escapeLabel(values[i])))
+		}
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) > 0 {
+		buf.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	buf.WriteString(" " + formatValue(value) + "\n")
+}
+
+// Counter - a value that only goes up, one per combination of label values.
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labels []string
+	value  float64
+}
+
+// NewCounter - registers a counter with the given label names.
+func (r *Registry) NewCounter(name string, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name, help, "counter", labels}, values: map[string]*counterValue{}}
+	r.register(c)
+	return c
+}
+
+// Add - adds delta to the counter with labels.
+func (c *Counter) Add(delta float64, labels ...string) {
+	key := strings.Join(labels, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[key]
+	if !ok {
+		v = &counterValue{labels: labels}
+		c.values[key] = v
+	}
+	v.value += delta
+}
+
+// Inc - adds one to the counter with labels.
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+func (c *Counter) write(buf *bytes.Buffer) {
+	c.header(buf)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		v := c.values[key]
+		c.line(buf, c.name, v.labels, "", v.value)
+	}
+}
+
+// Histogram - counts observations into buckets, one per combination of label
+// values.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram - registers a histogram with the given buckets and label names.
+func (r *Registry) NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{desc: desc{name, help, "histogram", labels}, buckets: buckets, values: map[string]*histogramValue{}}
+	r.register(h)
+	return h
+}
+
+// Observe - records value in the histogram with labels.
+func (h *Histogram) Observe(value float64, labels ...string) {
+	key := strings.Join(labels, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{labels: labels, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			v.counts[i]++
+		}
+	}
+	v.count++
+	v.sum += value
+}
+
+func (h *Histogram) write(buf *bytes.Buffer) {
+	h.header(buf)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		v := h.values[key]
+		for i, bound := range h.buckets {
+			h.line(buf, h.name+"_bucket", v.labels, fmt.Sprintf("le=\"%s\"", formatValue(bound)), float64(v.counts[i]))
+		}
+		h.line(buf, h.name+"_bucket", v.labels, "le=\"+Inf\"", float64(v.count))
+		h.line(buf, h.name+"_sum", v.labels, "", v.sum)
+		h.line(buf, h.name+"_count", v.labels, "", float64(v.count))
+	}
+}
+
+// GaugeFunc - a value read when the metrics are scraped.
+type GaugeFunc struct {
+	desc
+	collect func() []Sample
+}
+
+// NewGaugeFunc - registers a gauge whose samples are returned by collect.
+func (r *Registry) NewGaugeFunc(name string, help string, collect func() []Sample, labels ...string) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name, help, "gauge", labels}, collect: collect}
+	r.register(g)
+	return g
+}
+
+// NewCounterFunc - registers a counter whose samples are returned by collect,
+// for counters kept elsewhere.
+func (r *Registry) NewCounterFunc(name string, help string, collect func() []Sample, labels ...string) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name, help, "counter", labels}, collect: collect}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(buf *bytes.Buffer) {
+	g.header(buf)
+	for _, sample := range g.collect() {
+		g.line(buf, g.name, sample.Labels, "", sample.Value)
+	}
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	switch values := m.(type) {
+	case map[string]*counterValue:
+		for key := range values {
+			keys = append(keys, key)
+		}
+	case map[string]*histogramValue:
+		for key := range values {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(value string) string {
+	return helpEscaper.Replace(value)
+}
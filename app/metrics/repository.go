@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+)
+
+// RepositoryHook - a repository.Hook recording how long every operation takes
+// and how it ends.
+func (m *Metrics) RepositoryHook(ctx context.Context, operation string) (context.Context, func(err error)) {
+	start := time.Now()
+	return ctx, func(err error) {
+		outcome := "ok"
+		if err != nil {
+			outcome = "error"
+			if repoErr := repository.AsError(err); repoErr != nil {
+				outcome = repoErr.Kind.String()
+			}
+		}
+		m.repositoryDuration.Observe(since(start), operation, outcome)
+	}
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"context"
+
+	auth "github.com/softcorp-io/hqs-privileges-service/auth"
+	userservice "github.com/softcorp-io/hqs-privileges-service/userservice"
+	userProto "github.com/softcorp-io/hqs_proto/go_hqs/hqs_user_service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// validator - counts the outcome of every token validation.
+type validator struct {
+	next    auth.TokenValidator
+	metrics *Metrics
+}
+
+// InstrumentValidator - returns next counting whether tokens were valid,
+// rejected or could not be validated.
+func (m *Metrics) InstrumentValidator(next auth.TokenValidator) auth.TokenValidator {
+	return &validator{next, m}
+}
+
+// ValidateToken - validates token with the wrapped validator.
+func (v *validator) ValidateToken(ctx context.Context, token string) (*userProto.Token, error) {
+	result, err := v.next.ValidateToken(ctx, token)
+	outcome := "valid"
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.Unauthenticated, codes.PermissionDenied, codes.InvalidArgument, codes.NotFound:
+		outcome = "rejected"
+	default:
+		outcome = "error"
+	}
+	v.metrics.validations.Inc(outcome)
+	return result, err
+}
+
+// ExportUserService - exposes the call statistics and circuit breaker state
+// of the user service client.
+func (m *Metrics) ExportUserService(users *userservice.Client) {
+	r := m.Registry
+	r.NewCounterFunc("hqs_user_service_calls_total", "Calls made to the user service, retries included.", func() []Sample {
+		return []Sample{{nil, float64(users.Stats().Calls)}}
+	})
+	r.NewCounterFunc("hqs_user_service_failures_total", "Calls to the user service that failed.", func() []Sample {
+		return []Sample{{nil, float64(users.Stats().Failures)}}
+	})
+	r.NewCounterFunc("hqs_user_service_retries_total", "Calls to the user service that were retried.", func() []Sample {
+		return []Sample{{nil, float64(users.Stats().Retries)}}
+	})
+	r.NewCounterFunc("hqs_user_service_rejected_total", "Calls refused by the open circuit breaker.", func() []Sample {
+		return []Sample{{nil, float64(users.Stats().Rejected)}}
+	})
+	r.NewCounterFunc("hqs_user_service_breaker_opens_total", "How often the circuit breaker opened.", func() []Sample {
+		return []Sample{{nil, float64(users.Stats().BreakerOpens)}}
+	})
+	r.NewGaugeFunc("hqs_user_service_breaker_state", "1 for the current state of the circuit breaker.", func() []Sample {
+		state := users.Stats().BreakerState
+		samples := []Sample{}
+		for _, s := range []string{userservice.StateClosed, userservice.StateOpen, userservice.StateHalfOpen} {
+			value := 0.0
+			if s == state {
+				value = 1
+			}
+			samples = append(samples, Sample{[]string{s}, value})
+		}
+		return samples
+	}, "state")
+}
+
+// ExportTokenCache - exposes the hits, misses and size of the token cache.
+func (m *Metrics) ExportTokenCache(cache *auth.Cache) {
+	r := m.Registry
+	r.NewCounterFunc("hqs_token_cache_requests_total", "Token cache lookups, by result.", func() []Sample {
+		stats := cache.Stats()
+		return []Sample{
+			{[]string{"hit"}, float64(stats.Hits)},
+			{[]string{"miss"}, float64(stats.Misses)},
+		}
+	}, "result")
+	r.NewGaugeFunc("hqs_token_cache_entries", "Tokens currently cached.", func() []Sample {
+		return []Sample{{nil, float64(cache.Stats().Entries)}}
+	})
+}
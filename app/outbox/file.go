@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+)
+
+// FileSink - appends events to a file as json lines.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink - returns a sink appending to the file at path, which is
+// created if missing.
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file}, nil
+}
+
+// Publish - writes event as a line and syncs the file, so a published event
+// survives a crash.
+func (s *FileSink) Publish(ctx context.Context, event *repository.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close - closes the file.
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
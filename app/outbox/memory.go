@@ -0,0 +1,45 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+)
+
+// MemorySink - keeps published events in memory, for tests and for services
+// embedding the relay.
+type MemorySink struct {
+	mu     sync.Mutex
+	events []*repository.Event
+	// Fail - when set, the error every publish fails with.
+	Fail error
+}
+
+// NewMemorySink - returns an empty MemorySink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Publish - keeps event, unless the sink is set to fail.
+func (s *MemorySink) Publish(ctx context.Context, event *repository.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Fail != nil {
+		return s.Fail
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+// Events - the events published so far, in the order they were published.
+func (s *MemorySink) Events() []*repository.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*repository.Event{}, s.events...)
+}
+
+// Close - does nothing.
+func (s *MemorySink) Close() error {
+	return nil
+}
@@ -0,0 +1,56 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+)
+
+// NATSSink - publishes events to a NATS JetStream stream, as the subject
+// prefix followed by the event type, such as "hqs.privileges.privilege.created".
+// The stream must exist and cover those subjects.
+type NATSSink struct {
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+	subject string
+}
+
+// NewNATSSink - connects to the NATS server at url.
+func NewNATSSink(url string, subject string) (*NATSSink, error) {
+	conn, err := nats.Connect(url, nats.Name("hqs-privileges-service"))
+	if err != nil {
+		return nil, err
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &NATSSink{conn, js, subject}, nil
+}
+
+// Publish - publishes event and waits for the stream to store it. The event id
+// is the message id, so the stream drops events published twice within its
+// duplicate window.
+func (s *NATSSink) Publish(ctx context.Context, event *repository.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	_, err = s.js.PublishMsg(&nats.Msg{
+		Subject: s.subject + "." + event.Type,
+		Data:    data,
+	}, nats.MsgId(event.ID), nats.Context(ctx))
+	return err
+}
+
+// Close - closes the connection, after sending what is buffered.
+func (s *NATSSink) Close() error {
+	return s.conn.Drain()
+}
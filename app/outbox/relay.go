@@ -0,0 +1,159 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"go.uber.org/zap"
+)
+
+// maxBackoff - longest wait between two attempts to publish an event.
+const maxBackoff = time.Hour
+
+// Store - the outbox events are taken from, see repository.MongoRepository.
+type Store interface {
+	ClaimEvents(ctx context.Context, limit int64, lease time.Duration) ([]*repository.OutboxEntry, error)
+	MarkPublished(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id string, attempts int, reason string, next time.Time, dead bool) error
+	PurgePublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Options - how the relay delivers events.
+type Options struct {
+	BatchSize    int64
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	Retention    time.Duration
+}
+
+// Relay - publishes the events in the outbox to a sink, each at least once.
+// Failed events are retried with a doubling backoff and dead-lettered after
+// too many attempts. Several relays may share an outbox.
+type Relay struct {
+	store  Store
+	sink   Sink
+	opts   Options
+	zapLog *zap.Logger
+	notify chan struct{}
+}
+
+// NewRelay - returns a relay publishing from store to sink.
+func NewRelay(store Store, sink Sink, opts Options, zapLog *zap.Logger) *Relay {
+	return &Relay{store, sink, opts, zapLog, make(chan struct{}, 1)}
+}
+
+// Notify - asks the relay to look for events now rather than at its next
+// poll. It never blocks.
+func (r *Relay) Notify() {
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run - publishes events every interval, or when notified, until ctx is
+// cancelled.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastPurge := time.Time{}
+	for {
+		for {
+			published, err := r.Publish(ctx)
+			if err != nil {
+				r.zapLog.Error(fmt.Sprintf("Could not publish events with err %v", err))
+			}
+			// a full batch means more may be waiting
+			if err != nil || published < r.opts.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		if time.Since(lastPurge) > r.opts.Retention/24 {
+			r.purge(ctx)
+			lastPurge = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.notify:
+		}
+	}
+}
+
+// Publish - publishes one batch of due events, returning how many were
+// claimed.
+func (r *Relay) Publish(ctx context.Context) (int64, error) {
+	entries, err := r.store.ClaimEvents(ctx, r.opts.BatchSize, r.lease())
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		r.deliver(ctx, entry)
+	}
+	return int64(len(entries)), nil
+}
+
+// lease - how long claimed events are kept from other relays, long enough to
+// publish a whole batch.
+func (r *Relay) lease() time.Duration {
+	return publishTimeout*time.Duration(r.opts.BatchSize) + time.Minute
+}
+
+// deliver - publishes entry and records how it went.
+func (r *Relay) deliver(ctx context.Context, entry *repository.OutboxEntry) {
+	err := r.sink.Publish(ctx, &entry.Event)
+	if err != nil && ctx.Err() != nil {
+		// shutting down, the event is published again once its lease runs out
+		return
+	}
+	if err == nil {
+		if err := r.store.MarkPublished(ctx, entry.ID); err != nil {
+			// the event is published again once its lease runs out
+			r.zapLog.Error(fmt.Sprintf("Could not mark event %s published with err %v", entry.ID, err))
+		}
+		return
+	}
+
+	attempts := entry.Attempts + 1
+	dead := attempts >= r.opts.MaxAttempts
+	if dead {
+		r.zapLog.Error(fmt.Sprintf("Giving up on event %s after %d attempts with err %v", entry.ID, attempts, err),
+			zap.String("event_id", entry.ID), zap.String("event_type", entry.Type), zap.String("privilege_id", entry.PrivilegeID))
+	} else {
+		r.zapLog.Warn(fmt.Sprintf("Could not publish event %s with err %v", entry.ID, err),
+			zap.String("event_id", entry.ID), zap.Int("attempts", attempts))
+	}
+
+	if err := r.store.MarkFailed(ctx, entry.ID, attempts, err.Error(), time.Now().Add(r.backoff(attempts)), dead); err != nil {
+		r.zapLog.Error(fmt.Sprintf("Could not mark event %s failed with err %v", entry.ID, err))
+	}
+}
+
+// backoff - the wait after the given number of failed attempts.
+func (r *Relay) backoff(attempts int) time.Duration {
+	wait := r.opts.RetryBackoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// purge - removes events published longer ago than the retention.
+func (r *Relay) purge(ctx context.Context) {
+	purged, err := r.store.PurgePublished(ctx, time.Now().Add(-r.opts.Retention))
+	if err != nil {
+		r.zapLog.Error(fmt.Sprintf("Could not purge published events with err %v", err))
+	} else if purged > 0 {
+		r.zapLog.Info(fmt.Sprintf("Purged %d published events", purged))
+	}
+}
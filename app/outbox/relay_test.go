@@ -0,0 +1,186 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"go.uber.org/zap"
+)
+
+// memoryStore - an outbox kept in memory.
+type memoryStore struct {
+	entries []*repository.OutboxEntry
+}
+
+func (s *memoryStore) ClaimEvents(ctx context.Context, limit int64, lease time.Duration) ([]*repository.OutboxEntry, error) {
+	claimed := []*repository.OutboxEntry{}
+	now := time.Now()
+	for _, entry := range s.entries {
+		if int64(len(claimed)) >= limit {
+			break
+		}
+		if entry.PublishedAt != nil || entry.DeadAt != nil || entry.NextAttempt.After(now) || entry.LockedUntil.After(now) {
+			continue
+		}
+		entry.LockedUntil = now.Add(lease)
+		copied := *entry
+		claimed = append(claimed, &copied)
+	}
+	return claimed, nil
+}
+
+func (s *memoryStore) find(id string) *repository.OutboxEntry {
+	for _, entry := range s.entries {
+		if entry.ID == id {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) MarkPublished(ctx context.Context, id string) error {
+	now := time.Now()
+	s.find(id).PublishedAt = &now
+	return nil
+}
+
+func (s *memoryStore) MarkFailed(ctx context.Context, id string, attempts int, reason string, next time.Time, dead bool) error {
+	entry := s.find(id)
+	entry.Attempts = attempts
+	entry.LastError = reason
+	entry.NextAttempt = next
+	entry.LockedUntil = time.Time{}
+	if dead {
+		now := time.Now()
+		entry.DeadAt = &now
+	}
+	return nil
+}
+
+func (s *memoryStore) PurgePublished(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestRelayPublish(t *testing.T) {
+	refused := errors.New("refused")
+
+	tests := []struct {
+		name string
+		// attempts the event already had
+		attempts  int
+		fail      error
+		cancelled bool
+		published bool
+		dead      bool
+		// attempts and backoff recorded, when the event failed
+		failedAttempts int
+		backoff        time.Duration
+	}{
+		{"published", 0, nil, false, true, false, 0, 0},
+		{"first failure is retried", 0, refused, false, false, false, 1, time.Second},
+		{"later failures back off longer", 1, refused, false, false, false, 2, 2 * time.Second},
+		{"last attempt is dead-lettered", 2, refused, false, false, true, 3, 4 * time.Second},
+		{"shutting down leaves the event", 2, refused, true, false, false, 2, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := &memoryStore{entries: []*repository.OutboxEntry{{
+				Event:    repository.Event{ID: "event", Type: repository.EventPrivilegeCreated},
+				Attempts: test.attempts,
+			}}}
+			sink := NewMemorySink()
+			sink.Fail = test.fail
+			relay := NewRelay(store, sink, Options{BatchSize: 10, MaxAttempts: 3, RetryBackoff: time.Second}, zap.NewNop())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if test.cancelled {
+				cancel()
+			}
+			defer cancel()
+
+			start := time.Now()
+			claimed, err := relay.Publish(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claimed != 1 {
+				t.Fatalf("expected 1 event claimed, got %d", claimed)
+			}
+
+			entry := store.entries[0]
+			if (entry.PublishedAt != nil) != test.published {
+				t.Fatalf("expected published %v, got %v", test.published, entry.PublishedAt)
+			}
+			if (entry.DeadAt != nil) != test.dead {
+				t.Fatalf("expected dead %v, got %v", test.dead, entry.DeadAt)
+			}
+			if test.published {
+				if events := sink.Events(); len(events) != 1 || events[0].ID != "event" {
+					t.Fatalf("expected the event in the sink, got %v", events)
+				}
+				return
+			}
+			if entry.Attempts != test.failedAttempts {
+				t.Fatalf("expected %d attempts, got %d", test.failedAttempts, entry.Attempts)
+			}
+			if test.backoff > 0 {
+				wait := entry.NextAttempt.Sub(start)
+				if wait < test.backoff || wait > test.backoff+time.Second {
+					t.Fatalf("expected a backoff of %v, got %v", test.backoff, wait)
+				}
+				if entry.LastError != refused.Error() {
+					t.Fatalf("expected last error %q, got %q", refused.Error(), entry.LastError)
+				}
+			}
+		})
+	}
+}
+
+func TestRelayDeadEventsAreNotClaimed(t *testing.T) {
+	store := &memoryStore{entries: []*repository.OutboxEntry{{
+		Event: repository.Event{ID: "event", Type: repository.EventPrivilegeCreated},
+	}}}
+	sink := NewMemorySink()
+	sink.Fail = errors.New("refused")
+	relay := NewRelay(store, sink, Options{BatchSize: 10, MaxAttempts: 1, RetryBackoff: time.Millisecond}, zap.NewNop())
+
+	for round, expected := range []int64{1, 0} {
+		claimed, err := relay.Publish(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claimed != expected {
+			t.Fatalf("round %d: expected %d events claimed, got %d", round, expected, claimed)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if store.entries[0].DeadAt == nil {
+		t.Fatal("expected the event to be dead-lettered")
+	}
+}
+
+func TestRelayBackoff(t *testing.T) {
+	relay := NewRelay(&memoryStore{}, NewMemorySink(), Options{RetryBackoff: time.Minute}, zap.NewNop())
+
+	tests := []struct {
+		attempts int
+		wait     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{6, 32 * time.Minute},
+		{7, maxBackoff},
+		{100, maxBackoff},
+	}
+
+	for _, test := range tests {
+		if wait := relay.backoff(test.attempts); wait != test.wait {
+			t.Fatalf("backoff(%d) = %v, expected %v", test.attempts, wait, test.wait)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+)
+
+// publishTimeout - longest a sink may take to publish a single event.
+const publishTimeout = 10 * time.Second
+
+// Sink - where events are published to. Publish returns once the sink has
+// taken the event for good, an error makes the relay try again later.
+type Sink interface {
+	Publish(ctx context.Context, event *repository.Event) error
+	Close() error
+}
+
+// NewSink - the sink cfg asks for, or nil when events are not published.
+func NewSink(cfg config.Outbox) (Sink, error) {
+	switch cfg.Sink {
+	case config.OutboxNone, "":
+		return nil, nil
+	case config.OutboxWebhook:
+		return NewWebhookSink(cfg.WebhookURL, cfg.WebhookSecret), nil
+	case config.OutboxFile:
+		sink, err := NewFileSink(cfg.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		return sink, nil
+	case config.OutboxNATS:
+		sink, err := NewNATSSink(cfg.NATSURL, cfg.NATSSubject)
+		if err != nil {
+			return nil, err
+		}
+		return sink, nil
+	default:
+		return nil, errors.New("Unknown outbox sink " + cfg.Sink)
+	}
+}
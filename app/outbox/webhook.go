@@ -0,0 +1,84 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+)
+
+// Headers sent along with every event posted to a webhook.
+const (
+	EventIDHeader   = "X-Event-Id"
+	EventTypeHeader = "X-Event-Type"
+	SignatureHeader = "X-Signature"
+)
+
+// WebhookSink - posts events as json to a url. Any 2xx response counts as
+// delivered.
+type WebhookSink struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookSink - returns a sink posting to url. With a secret every body is
+// signed, the signature being "sha256=" followed by the hex HMAC-SHA256 of the
+// body.
+func NewWebhookSink(url string, secret string) *WebhookSink {
+	return &WebhookSink{url, secret, &http.Client{Timeout: publishTimeout}}
+}
+
+// Publish - posts event, receivers should use the event id header to skip
+// events they have seen.
+func (s *WebhookSink) Publish(ctx context.Context, event *repository.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, event.ID)
+	req.Header.Set(EventTypeHeader, event.Type)
+	if s.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(s.secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// drain so the connection is reused
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Webhook answered with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign - the signature of body with secret, as sent in the signature header.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Close - closes idle connections.
+func (s *WebhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
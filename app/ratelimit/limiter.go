@@ -0,0 +1,186 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	auth "github.com/softcorp-io/hqs-privileges-service/auth"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// RetryAfterHeader - metadata key telling a limited caller how many seconds
+// to wait.
+const RetryAfterHeader = "retry-after"
+
+// busyRetry - how long a caller turned away by a concurrency limit is asked to
+// wait.
+const busyRetry = time.Second
+
+// sweepInterval - how often buckets of callers gone quiet are dropped.
+const sweepInterval = time.Minute
+
+// bucketKey - a caller of a method.
+type bucketKey struct {
+	caller string
+	method string
+}
+
+// bucket - the tokens a caller has left for a method, refilled at the rate of
+// the rule applying to it.
+type bucket struct {
+	rate   Rate
+	tokens float64
+	last   time.Time
+}
+
+// Limiter - limits how often each caller may call a method and how many
+// calls to a method run at once. Methods without a limit are not limited.
+type Limiter struct {
+	rates map[string]Rate
+	slots map[string]chan struct{}
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+// NewLimiter - returns a limiter enforcing rates per caller and concurrency
+// across every caller, both by method.
+func NewLimiter(rates map[string]Rate, concurrency map[string]int64) *Limiter {
+	slots := map[string]chan struct{}{}
+	for method, max := range concurrency {
+		slots[method] = make(chan struct{}, max)
+	}
+	return &Limiter{
+		rates:     rates,
+		slots:     slots,
+		buckets:   map[bucketKey]*bucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// UnaryInterceptor - limits unary calls. It must run after the guard, as
+// callers are told apart by their identity.
+func (l *Limiter) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	release, err := l.acquire(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return handler(ctx, req)
+}
+
+// StreamInterceptor - limits streaming calls, holding a concurrency slot
+// until the stream ends.
+func (l *Limiter) StreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	release, err := l.acquire(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return handler(srv, stream)
+}
+
+// acquire - takes a token for the caller in ctx and a slot for method, or
+// returns a ResourceExhausted error telling the caller when to retry.
+func (l *Limiter) acquire(ctx context.Context, method string) (func(), error) {
+	if key, ok := ruleKey(method, func(key string) bool { _, ok := l.rates[key]; return ok }); ok {
+		// every method has buckets of its own, even those sharing a rule
+		if wait, ok := l.take(bucketKey{caller(ctx), method}, l.rates[key], time.Now()); !ok {
+			return nil, exhausted(ctx, fmt.Sprintf("Rate limit for %s exceeded", method), wait)
+		}
+	}
+
+	key, ok := ruleKey(method, func(key string) bool { _, ok := l.slots[key]; return ok })
+	if !ok {
+		return func() {}, nil
+	}
+	slots := l.slots[key]
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	default:
+		return nil, exhausted(ctx, fmt.Sprintf("Too many calls to %s at once", method), busyRetry)
+	}
+}
+
+// take - takes a token from the bucket of key at now, or returns how long
+// until there is one.
+func (l *Limiter) take(key bucketKey, rate Rate, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{rate: rate, tokens: rate.Burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(rate.Burst, b.tokens+now.Sub(b.last).Seconds()*rate.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / rate.Rate * float64(time.Second)), false
+}
+
+// sweep - drops buckets that have filled up again, they would be created as
+// they are.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.rate.Rate >= b.rate.Burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// caller - who is calling: the authenticated user or, for public methods, the
+// address the call came from.
+func caller(ctx context.Context) string {
+	if identity, ok := auth.FromContext(ctx); ok {
+		return "user:" + identity.UserID
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "addr:" + host
+	}
+	return "anonymous"
+}
+
+// exhausted - a ResourceExhausted error asking the caller to retry after
+// wait, both as metadata and as retry info.
+func exhausted(ctx context.Context, message string, wait time.Duration) error {
+	seconds := int64(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	// the gateway has no grpc transport to send headers on, it reads the
+	// retry info instead
+	grpc.SetHeader(ctx, metadata.Pairs(RetryAfterHeader, strconv.FormatInt(seconds, 10)))
+
+	s := status.New(codes.ResourceExhausted, fmt.Sprintf("%s, retry in %ds", message, seconds))
+	if detailed, err := s.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Duration(seconds) * time.Second)}); err == nil {
+		s = detailed
+	}
+	return s.Err()
+}
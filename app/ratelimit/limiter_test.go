@@ -0,0 +1,259 @@
+package ratelimit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	auth "github.com/softcorp-io/hqs-privileges-service/auth"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseRates(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		rates   map[string]Rate
+		fails   bool
+	}{
+		{"rates", []string{"*=50/100", "GetAll=0.5/2"}, map[string]Rate{"*": {50, 100}, "GetAll": {0.5, 2}}, false},
+		{"no entries", nil, map[string]Rate{}, false},
+		{"missing burst", []string{"GetAll=5"}, nil, true},
+		{"missing method", []string{"=5/10"}, nil, true},
+		{"zero rate", []string{"GetAll=0/10"}, nil, true},
+		{"zero burst", []string{"GetAll=5/0"}, nil, true},
+		{"fractional burst", []string{"GetAll=5/1.5"}, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rates, err := ParseRates(test.entries)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected %v to be refused", test.entries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rates, test.rates) {
+				t.Fatalf("expected %v, got %v", test.rates, rates)
+			}
+		})
+	}
+}
+
+func TestParseConcurrency(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		limits  map[string]int64
+		fails   bool
+	}{
+		{"limits", []string{"GetAll=8", "/svc/Check=1"}, map[string]int64{"GetAll": 8, "/svc/Check": 1}, false},
+		{"zero", []string{"GetAll=0"}, nil, true},
+		{"not a number", []string{"GetAll=many"}, nil, true},
+		{"missing value", []string{"GetAll="}, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limits, err := ParseConcurrency(test.entries)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected %v to be refused", test.entries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(limits, test.limits) {
+				t.Fatalf("expected %v, got %v", test.limits, limits)
+			}
+		})
+	}
+}
+
+func TestRuleKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		rules  []string
+		key    string
+		found  bool
+	}{
+		{"full name first", "/svc/GetAll", []string{"/svc/GetAll", "GetAll", AnyMethod}, "/svc/GetAll", true},
+		{"bare name", "/svc/GetAll", []string{"GetAll", AnyMethod}, "GetAll", true},
+		{"any method", "/svc/GetAll", []string{"Get", AnyMethod}, AnyMethod, true},
+		{"no rule", "/svc/GetAll", []string{"Get"}, "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			has := func(key string) bool {
+				for _, rule := range test.rules {
+					if rule == key {
+						return true
+					}
+				}
+				return false
+			}
+			key, found := ruleKey(test.method, has)
+			if key != test.key || found != test.found {
+				t.Fatalf("expected %q %v, got %q %v", test.key, test.found, key, found)
+			}
+		})
+	}
+}
+
+func TestTake(t *testing.T) {
+	rate := Rate{Rate: 2, Burst: 2}
+	start := time.Now()
+
+	tests := []struct {
+		name string
+		// seconds after start of every call
+		calls []float64
+		// whether the last call may go ahead and how long it must wait if not
+		ok   bool
+		wait time.Duration
+	}{
+		{"burst", []float64{0, 0}, true, 0},
+		{"past the burst", []float64{0, 0, 0}, false, 500 * time.Millisecond},
+		{"refilled", []float64{0, 0, 0.5}, true, 0},
+		{"partly refilled", []float64{0, 0, 0.25}, false, 250 * time.Millisecond},
+		{"refill stops at the burst", []float64{0, 0, 10, 10, 10}, false, 500 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := NewLimiter(map[string]Rate{AnyMethod: rate}, nil)
+			key := bucketKey{"user:a", "/svc/GetAll"}
+			var wait time.Duration
+			var ok bool
+			for _, at := range test.calls {
+				wait, ok = l.take(key, rate, start.Add(time.Duration(at*float64(time.Second))))
+			}
+			if ok != test.ok {
+				t.Fatalf("expected %v, got %v", test.ok, ok)
+			}
+			if wait != test.wait {
+				t.Fatalf("expected to wait %v, got %v", test.wait, wait)
+			}
+		})
+	}
+}
+
+// headerStream - a server transport stream keeping the headers set on it.
+type headerStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestAcquire(t *testing.T) {
+	rates := map[string]Rate{AnyMethod: {Rate: 1, Burst: 1}}
+
+	tests := []struct {
+		name string
+		// methods called by the caller, in turn
+		methods []string
+		callers []string
+		// code of the last call and its retry after header
+		code       codes.Code
+		retryAfter string
+	}{
+		{"first call", []string{"/svc/Get"}, []string{"a"}, codes.OK, ""},
+		{"same method again", []string{"/svc/Get", "/svc/Get"}, []string{"a", "a"}, codes.ResourceExhausted, "1"},
+		{"methods sharing a rule", []string{"/svc/Get", "/svc/GetAll"}, []string{"a", "a"}, codes.OK, ""},
+		{"other caller", []string{"/svc/Get", "/svc/Get"}, []string{"a", "b"}, codes.OK, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := NewLimiter(rates, nil)
+			var err error
+			var stream *headerStream
+			for i, method := range test.methods {
+				stream = &headerStream{}
+				ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+				ctx = auth.NewContext(ctx, &auth.Identity{UserID: test.callers[i]})
+				var release func()
+				release, err = l.acquire(ctx, method)
+				if err == nil {
+					release()
+				}
+			}
+
+			if status.Code(err) != test.code {
+				t.Fatalf("expected %v, got %v", test.code, err)
+			}
+			retryAfter := stream.header.Get(RetryAfterHeader)
+			if test.retryAfter == "" {
+				if len(retryAfter) != 0 {
+					t.Fatalf("expected no retry after header, got %v", retryAfter)
+				}
+				return
+			}
+			if len(retryAfter) != 1 || retryAfter[0] != test.retryAfter {
+				t.Fatalf("expected retry after %s, got %v", test.retryAfter, retryAfter)
+			}
+			details := status.Convert(err).Details()
+			if len(details) != 1 {
+				t.Fatalf("expected retry info, got %v", details)
+			}
+			if info, ok := details[0].(*errdetails.RetryInfo); !ok || info.RetryDelay.AsDuration() != time.Second {
+				t.Fatalf("expected a retry delay of 1s, got %v", details[0])
+			}
+		})
+	}
+}
+
+func TestAcquireReleasesSlots(t *testing.T) {
+	tests := []struct {
+		name string
+		// whether the first call is done before the second one starts
+		released bool
+		code     codes.Code
+	}{
+		{"slot taken", false, codes.ResourceExhausted},
+		{"slot released", true, codes.OK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := NewLimiter(nil, map[string]int64{"Check": 1})
+			ctx := context.Background()
+
+			release, err := l.acquire(ctx, "/svc/Check")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.released {
+				release()
+			}
+
+			second, err := l.acquire(ctx, "/svc/Check")
+			if status.Code(err) != test.code {
+				t.Fatalf("expected %v, got %v", test.code, err)
+			}
+			if err == nil {
+				second()
+			}
+			if _, err := l.acquire(ctx, "/svc/Other"); err != nil {
+				t.Fatalf("expected methods without a limit to go ahead, got %v", err)
+			}
+		})
+	}
+}
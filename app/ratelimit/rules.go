@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// AnyMethod - stands for every method without a limit of its own.
+const AnyMethod = "*"
+
+// Rate - how many calls a caller may make to a method: Rate a second on
+// average, up to Burst at once.
+type Rate struct {
+	Rate  float64
+	Burst float64
+}
+
+// ParseRates - reads "method=rate/burst" entries. Methods are full grpc
+// method names, bare method names or AnyMethod.
+func ParseRates(entries []string) (map[string]Rate, error) {
+	rates := map[string]Rate{}
+	for _, entry := range entries {
+		method, value, err := split(entry)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.SplitN(value, "/", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("Rate limit " + entry + " must look like method=rate/burst")
+		}
+		rate, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil || rate <= 0 {
+			return nil, errors.New("Rate limit " + entry + " must have a positive rate")
+		}
+		burst, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || burst < 1 {
+			return nil, errors.New("Rate limit " + entry + " must have a burst of at least 1")
+		}
+		rates[method] = Rate{rate, float64(burst)}
+	}
+	return rates, nil
+}
+
+// ParseConcurrency - reads "method=max" entries, the most calls to a method
+// running at once across every caller.
+func ParseConcurrency(entries []string) (map[string]int64, error) {
+	limits := map[string]int64{}
+	for _, entry := range entries {
+		method, value, err := split(entry)
+		if err != nil {
+			return nil, err
+		}
+		max, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || max < 1 {
+			return nil, errors.New("Concurrency limit " + entry + " must look like method=max with max at least 1")
+		}
+		limits[method] = max
+	}
+	return limits, nil
+}
+
+func split(entry string) (string, string, error) {
+	parts := strings.SplitN(entry, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.New("Limit " + entry + " must look like method=value")
+	}
+	return parts[0], parts[1], nil
+}
+
+// ruleKey - the key of the rule applying to fullMethod, of those has knows:
+// its full name, its bare name or AnyMethod, in that order.
+func ruleKey(fullMethod string, has func(key string) bool) (string, bool) {
+	name := fullMethod
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		name = fullMethod[i+1:]
+	}
+	for _, key := range []string{fullMethod, name, AnyMethod} {
+		if has(key) {
+			return key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+)
+
+type actorKey struct{}
+
+// WithActor - returns ctx naming the user a change is made by, recorded on
+// audit entries.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// actorFrom - the user changes in ctx are made by, if known.
+func actorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
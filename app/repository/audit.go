@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// AuditEntry - struct describing a change that must be traceable afterwards.
+type AuditEntry struct {
+	ID          string    `bson:"id" json:"id"`
+	Action      string    `bson:"action" json:"action"`
+	PrivilegeID string    `bson:"privilege_id,omitempty" json:"privilege_id,omitempty"`
+	GroupID     string    `bson:"group_id,omitempty" json:"group_id,omitempty"`
+	UserID      string    `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Actor       string    `bson:"actor,omitempty" json:"actor,omitempty"`
+	Forced      bool      `bson:"forced" json:"forced"`
+	Message     string    `bson:"message" json:"message"`
+	CreatedAt   time.Time `bson:"created_at" json:"created_at"`
+}
+
+// audit - stores an audit entry.
+func (r *MongoRepository) audit(ctx context.Context, entry *AuditEntry) error {
+	entry.ID = uuid.NewV4().String()
+	entry.CreatedAt = time.Now()
+	if entry.Actor == "" {
+		entry.Actor = actorFrom(ctx)
+	}
+
+	_, err := r.mongoAudit.InsertOne(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// auditAfter - stores an audit entry once the change being made in the
+// transaction in ctx succeeded, or right away outside a transaction.
+func (r *MongoRepository) auditAfter(ctx context.Context, entry *AuditEntry) error {
+	if pending, ok := ctx.Value(pendingKey{}).(*pendingChanges); ok {
+		pending.audits = append(pending.audits, entry)
+		return nil
+	}
+	return r.audit(ctx, entry)
+}
+
+// auditPending - stores the audit entries kept by auditAfter.
+func (r *MongoRepository) auditPending(ctx context.Context, pending *pendingChanges) error {
+	for _, entry := range pending.audits {
+		if err := r.audit(ctx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// BreakGlassPrefix - prefix of every break glass credential, so normal tokens
+// never cause a lookup.
+const BreakGlassPrefix = "breakglass."
+
+// MaxBreakGlassTTL - longest time a break glass credential can grant access.
+const MaxBreakGlassTTL = 24 * time.Hour
+
+// BreakGlass - an emergency credential granting root access for a limited
+// time and number of uses. Only the hash of the credential is stored.
+type BreakGlass struct {
+	ID        string     `bson:"id" json:"id"`
+	TokenHash string     `bson:"token_hash" json:"-"`
+	Reason    string     `bson:"reason" json:"reason"`
+	Uses      int        `bson:"uses" json:"uses"`
+	MaxUses   int        `bson:"max_uses" json:"max_uses"`
+	IssuedAt  time.Time  `bson:"issued_at" json:"issued_at"`
+	ExpiresAt time.Time  `bson:"expires_at" json:"expires_at"`
+	RevokedAt *time.Time `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+func hashBreakGlass(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueBreakGlass - creates a new break glass credential and revokes every
+// earlier one. The credential is only returned here.
+func (r *MongoRepository) IssueBreakGlass(ctx context.Context, ttl time.Duration, maxUses int, reason string) (string, *BreakGlass, error) {
+	if ttl <= 0 || ttl > MaxBreakGlassTTL {
+		return "", nil, invalid("ttl", fmt.Sprintf("TTL must be between 0 and %v", MaxBreakGlassTTL))
+	}
+	if maxUses <= 0 {
+		return "", nil, invalid("max_uses", "Uses must be positive")
+	}
+	if strings.Trim(reason, " ") == "" {
+		return "", nil, invalid("reason", "Reason is required")
+	}
+
+	if _, err := r.RevokeBreakGlass(ctx); err != nil {
+		return "", nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	token := BreakGlassPrefix + hex.EncodeToString(secret)
+
+	glass := &BreakGlass{
+		ID:        uuid.NewV4().String(),
+		TokenHash: hashBreakGlass(token),
+		Reason:    reason,
+		MaxUses:   maxUses,
+		IssuedAt:  time.Now(),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if _, err := r.mongoBreakGlass.InsertOne(ctx, glass); err != nil {
+		return "", nil, err
+	}
+
+	err := r.audit(ctx, &AuditEntry{
+		Action:  "break_glass_issue",
+		Message: fmt.Sprintf("Break glass credential %s issued until %s for %d uses: %s", glass.ID, glass.ExpiresAt.Format(time.RFC3339), maxUses, reason),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, glass, nil
+}
+
+// UseBreakGlass - spends one use of the break glass credential token. Every
+// use is audited, and a use that cannot be audited is refused.
+func (r *MongoRepository) UseBreakGlass(ctx context.Context, token string) (*BreakGlass, error) {
+	if !strings.HasPrefix(token, BreakGlassPrefix) {
+		return nil, denied("NOT_BREAK_GLASS", "Not a break glass credential")
+	}
+
+	glass := BreakGlass{}
+	filter := bson.M{
+		"token_hash": hashBreakGlass(token),
+		"revoked_at": nil,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+	err := r.mongoBreakGlass.FindOneAndUpdate(
+		ctx,
+		bson.M{"$and": []bson.M{filter, {"$expr": bson.M{"$lt": []string{"$uses", "$max_uses"}}}}},
+		bson.M{"$inc": bson.M{"uses": 1}},
+	).Decode(&glass)
+	if err == mongo.ErrNoDocuments {
+		return nil, denied("BREAK_GLASS_INVALID", "Break glass credential is invalid, expired or used up")
+	}
+	if err != nil {
+		return nil, err
+	}
+	glass.Uses++
+
+	err = r.audit(ctx, &AuditEntry{
+		Action:  "break_glass_use",
+		Message: fmt.Sprintf("Break glass credential %s used (%d of %d), root access until %s: %s", glass.ID, glass.Uses, glass.MaxUses, glass.ExpiresAt.Format(time.RFC3339), glass.Reason),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &glass, nil
+}
+
+// RevokeBreakGlass - revokes every break glass credential still active.
+func (r *MongoRepository) RevokeBreakGlass(ctx context.Context) (int64, error) {
+	result, err := r.mongoBreakGlass.UpdateMany(
+		ctx,
+		bson.M{"revoked_at": nil, "expires_at": bson.M{"$gt": time.Now()}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	if result.ModifiedCount > 0 {
+		err = r.audit(ctx, &AuditEntry{
+			Action:  "break_glass_revoke",
+			Message: fmt.Sprintf("Revoked %d break glass credentials", result.ModifiedCount),
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return result.ModifiedCount, nil
+}
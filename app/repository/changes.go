@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"sync"
+)
+
+// Kinds of changes reported to change listeners.
+const (
+	ChangePrivilege = "privilege"
+	ChangeUser      = "user"
+	ChangeGroup     = "group"
+)
+
+// Change - describes a change that may alter the permissions of users: a
+// privilege, the privileges of a user, or a group changed.
+type Change struct {
+	Kind string
+	ID   string
+}
+
+// changeListeners - functions told about every change made through the
+// repository.
+type changeListeners struct {
+	mu        sync.RWMutex
+	listeners []func(Change)
+}
+
+// pendingChanges - changes made in a transaction, told about once it is
+// committed, and audit entries written once the changes are.
+type pendingChanges struct {
+	changes []Change
+	audits  []*AuditEntry
+}
+
+type pendingKey struct{}
+
+// OnChange - registers fn to be called after every change made through the
+// repository that may alter the permissions of users. Changes made in a
+// transaction are reported once it is committed. Changes made by other
+// processes are not reported.
+func (r *MongoRepository) OnChange(fn func(Change)) {
+	r.changes.mu.Lock()
+	defer r.changes.mu.Unlock()
+	r.changes.listeners = append(r.changes.listeners, fn)
+}
+
+// changed - tells every listener about a change, or keeps it until the
+// transaction in ctx is committed.
+func (r *MongoRepository) changed(ctx context.Context, kind string, id string) {
+	if pending, ok := ctx.Value(pendingKey{}).(*pendingChanges); ok {
+		pending.changes = append(pending.changes, Change{kind, id})
+		return
+	}
+	r.notify(Change{kind, id})
+}
+
+// notify - tells every listener about changes.
+func (r *MongoRepository) notify(changes ...Change) {
+	r.changes.mu.RLock()
+	defer r.changes.mu.RUnlock()
+	for _, change := range changes {
+		for _, fn := range r.changes.listeners {
+			fn(change)
+		}
+	}
+}
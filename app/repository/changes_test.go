@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTransactNotifiesAfterwards(t *testing.T) {
+	failed := errors.New("failed")
+
+	tests := []struct {
+		name string
+		fn   func(r *MongoRepository, ctx context.Context) error
+		err  error
+		// changes seen by listeners while fn ran and once transact returned
+		during []Change
+		after  []Change
+	}{
+		{"outside a transaction", nil, nil, nil, []Change{{ChangeGroup, "outside"}}},
+		{"kept until done", func(r *MongoRepository, ctx context.Context) error {
+			r.changed(ctx, ChangePrivilege, "a")
+			r.changed(ctx, ChangeUser, "b")
+			return nil
+		}, nil, []Change{}, []Change{{ChangePrivilege, "a"}, {ChangeUser, "b"}}},
+		{"nested transactions join", func(r *MongoRepository, ctx context.Context) error {
+			r.changed(ctx, ChangePrivilege, "a")
+			return r.transact(ctx, func(ctx context.Context) error {
+				r.changed(ctx, ChangeGroup, "b")
+				return nil
+			})
+		}, nil, []Change{}, []Change{{ChangePrivilege, "a"}, {ChangeGroup, "b"}}},
+		{"told on error without an outbox", func(r *MongoRepository, ctx context.Context) error {
+			r.changed(ctx, ChangePrivilege, "a")
+			return failed
+		}, failed, []Change{}, []Change{{ChangePrivilege, "a"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &MongoRepository{changes: &changeListeners{}}
+			seen := []Change{}
+			r.OnChange(func(change Change) {
+				seen = append(seen, change)
+			})
+
+			if test.fn == nil {
+				r.changed(context.Background(), ChangeGroup, "outside")
+			} else {
+				var during []Change
+				err := r.transact(context.Background(), func(ctx context.Context) error {
+					err := test.fn(r, ctx)
+					during = append([]Change{}, seen...)
+					return err
+				})
+				if err != test.err {
+					t.Fatalf("expected %v, got %v", test.err, err)
+				}
+				if !reflect.DeepEqual(during, test.during) {
+					t.Fatalf("expected %v while running, got %v", test.during, during)
+				}
+			}
+
+			if !reflect.DeepEqual(seen, test.after) {
+				t.Fatalf("expected %v, got %v", test.after, seen)
+			}
+		})
+	}
+}
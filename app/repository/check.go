@@ -0,0 +1,371 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Finding - a single inconsistency found by Check.
+type Finding struct {
+	Kind        string `json:"kind"`
+	PrivilegeID string `json:"privilege_id,omitempty"`
+	UserID      string `json:"user_id,omitempty"`
+	GroupID     string `json:"group_id,omitempty"`
+	Message     string `json:"message"`
+	Repaired    bool   `json:"repaired"`
+}
+
+// CheckReport - result of a consistency check.
+type CheckReport struct {
+	Findings   []*Finding `json:"findings"`
+	Repaired   int        `json:"repaired"`
+	Unrepaired int        `json:"unrepaired"`
+}
+
+// Kinds of findings reported by Check.
+const (
+	FindingDuplicateRoot        = "duplicate_root"
+	FindingDuplicateDefault     = "duplicate_default"
+	FindingInvalidPrivilege     = "invalid_privilege"
+	FindingMissingTimestamps    = "missing_timestamps"
+	FindingDanglingUser         = "dangling_user_privilege"
+	FindingDanglingGroup        = "dangling_group_privilege"
+	FindingUserWithoutPrivilege = "user_without_privilege"
+)
+
+type checker struct {
+	r      *MongoRepository
+	repair bool
+	report *CheckReport
+}
+
+// add - records a finding and, when repairing, runs fix and audits it in a
+// transaction of its own. fix records the events of what it changed.
+func (c *checker) add(ctx context.Context, finding *Finding, fix func(ctx context.Context) error) error {
+	c.report.Findings = append(c.report.Findings, finding)
+	if !c.repair || fix == nil {
+		c.report.Unrepaired++
+		return nil
+	}
+
+	err := c.r.transact(ctx, func(ctx context.Context) error {
+		if err := fix(ctx); err != nil {
+			return err
+		}
+		return c.r.audit(ctx, &AuditEntry{
+			Action:      "repair",
+			PrivilegeID: finding.PrivilegeID,
+			GroupID:     finding.GroupID,
+			UserID:      finding.UserID,
+			Message:     finding.Message,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	finding.Repaired = true
+	c.report.Repaired++
+
+	return nil
+}
+
+// Check - scans privileges, users and groups for dangling privilege ids,
+// duplicate root or default privileges, privileges breaking the validation
+// rules and missing timestamps. With repair every finding that can be fixed
+// safely is fixed, recorded in the outbox and audited, each fix in its own
+// transaction. Privileges breaking the validation rules are
+// only reported, as fixing them means guessing which permission is wrong.
+func (r *MongoRepository) Check(ctx context.Context, repair bool) (*CheckReport, error) {
+	c := &checker{r: r, repair: repair, report: &CheckReport{Findings: []*Finding{}}}
+
+	if err := c.checkSpecial(ctx, "root", FindingDuplicateRoot); err != nil {
+		return nil, err
+	}
+	if err := c.checkSpecial(ctx, "default", FindingDuplicateDefault); err != nil {
+		return nil, err
+	}
+
+	privileges, err := r.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	valid := map[string]bool{}
+	for _, priv := range privileges {
+		valid[priv.ID] = true
+		if err := c.checkPrivilege(ctx, priv); err != nil {
+			return nil, err
+		}
+	}
+
+	defaultPrivilege, err := r.GetDefault(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkUsers(ctx, valid, defaultPrivilege); err != nil {
+		return nil, err
+	}
+	if err := c.checkGroups(ctx, valid); err != nil {
+		return nil, err
+	}
+
+	return c.report, nil
+}
+
+// checkSpecial - finds more than one root or default privilege. The oldest is
+// kept, holders of the others are moved to it.
+func (c *checker) checkSpecial(ctx context.Context, flag string, kind string) error {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "id", Value: 1}})
+	cursor, err := c.r.mongo.Find(ctx, notDeleted(bson.M{flag: true}), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	privileges := []*Privilege{}
+	for cursor.Next(ctx) {
+		var tempPriv Privilege
+		if err := cursor.Decode(&tempPriv); err != nil {
+			return err
+		}
+		privileges = append(privileges, &tempPriv)
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	for i := 1; i < len(privileges); i++ {
+		kept, duplicate := privileges[0], privileges[i]
+		err := c.add(ctx, &Finding{
+			Kind:        kind,
+			PrivilegeID: duplicate.ID,
+			Message:     fmt.Sprintf("Privilege %s duplicates %s privilege %s", duplicate.ID, flag, kept.ID),
+		}, func(ctx context.Context) error {
+			return c.r.mergeInto(ctx, duplicate, kept.ID)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkPrivilege - checks the validation rules and timestamps of a privilege.
+func (c *checker) checkPrivilege(ctx context.Context, priv *Privilege) error {
+	if err := priv.validate("create"); err != nil {
+		err := c.add(ctx, &Finding{
+			Kind:        FindingInvalidPrivilege,
+			PrivilegeID: priv.ID,
+			Message:     fmt.Sprintf("Privilege %s is invalid: %v", priv.ID, err),
+		}, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if priv.CreatedAt.IsZero() || priv.UpdatedAt.IsZero() {
+		err := c.add(ctx, &Finding{
+			Kind:        FindingMissingTimestamps,
+			PrivilegeID: priv.ID,
+			Message:     fmt.Sprintf("Privilege %s is missing timestamps", priv.ID),
+		}, func(ctx context.Context) error {
+			now := time.Now()
+			set := bson.M{}
+			if priv.CreatedAt.IsZero() {
+				set["created_at"] = now
+				priv.CreatedAt = now
+			}
+			if priv.UpdatedAt.IsZero() {
+				set["updated_at"] = now
+				priv.UpdatedAt = now
+			}
+			_, err := c.r.mongo.UpdateOne(ctx, bson.M{"id": priv.ID}, bson.M{"$set": set})
+			if err != nil {
+				return err
+			}
+
+			c.r.changed(ctx, ChangePrivilege, priv.ID)
+			return c.r.record(ctx, Event{Type: EventPrivilegeUpdated, PrivilegeID: priv.ID, Privilege: priv})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkUsers - finds users pointing at privileges that do not exist.
+func (c *checker) checkUsers(ctx context.Context, valid map[string]bool, defaultPrivilege *Privilege) error {
+	opts := options.Find().SetProjection(bson.M{"id": 1, "privilege_id": 1, "privilege_ids": 1})
+	cursor, err := c.r.mongoUser.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var tempUser User
+		if err := cursor.Decode(&tempUser); err != nil {
+			return err
+		}
+
+		kept := []string{}
+		dangling := []string{}
+		for _, id := range tempUser.privileges() {
+			if valid[id] {
+				kept = append(kept, id)
+			} else {
+				dangling = append(dangling, id)
+			}
+		}
+		if tempUser.PrivilegeID != "" && !valid[tempUser.PrivilegeID] && len(dangling) == 0 {
+			dangling = append(dangling, tempUser.PrivilegeID)
+		}
+		if len(kept) == 0 {
+			kept = append(kept, defaultPrivilege.ID)
+		}
+
+		userID := tempUser.ID
+		if len(dangling) > 0 {
+			err = c.add(ctx, &Finding{
+				Kind:    FindingDanglingUser,
+				UserID:  userID,
+				Message: fmt.Sprintf("User %s points at missing privileges %v", userID, dangling),
+			}, func(ctx context.Context) error {
+				if err := c.r.setUserPrivileges(ctx, userID, kept); err != nil {
+					return err
+				}
+				for _, id := range dangling {
+					err := c.r.record(ctx, Event{Type: EventPrivilegeRevoked, PrivilegeID: id, UserID: userID, PrivilegeIDs: kept})
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		} else if len(tempUser.privileges()) == 0 {
+			err = c.add(ctx, &Finding{
+				Kind:    FindingUserWithoutPrivilege,
+				UserID:  userID,
+				Message: fmt.Sprintf("User %s has no privilege", userID),
+			}, func(ctx context.Context) error {
+				if err := c.r.setUserPrivileges(ctx, userID, kept); err != nil {
+					return err
+				}
+				return c.r.record(ctx, Event{Type: EventPrivilegeAssigned, PrivilegeID: kept[0], UserID: userID, PrivilegeIDs: kept})
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// checkGroups - finds groups granting privileges that do not exist.
+func (c *checker) checkGroups(ctx context.Context, valid map[string]bool) error {
+	groups, err := c.r.GetAllGroups(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		dangling := []string{}
+		for _, id := range group.PrivilegeIDs {
+			if !valid[id] {
+				dangling = append(dangling, id)
+			}
+		}
+		if len(dangling) == 0 {
+			continue
+		}
+
+		groupID := group.ID
+		err := c.add(ctx, &Finding{
+			Kind:    FindingDanglingGroup,
+			GroupID: groupID,
+			Message: fmt.Sprintf("Group %s points at missing privileges %v", groupID, dangling),
+		}, func(ctx context.Context) error {
+			_, err := c.r.mongoGroup.UpdateOne(
+				ctx,
+				bson.M{"id": groupID},
+				bson.M{
+					"$pull": bson.M{"privilege_ids": bson.M{"$in": dangling}},
+					"$set":  bson.M{"updated_at": time.Now()},
+				},
+			)
+			if err != nil {
+				return err
+			}
+
+			updated, err := c.r.GetGroup(ctx, groupID)
+			if err != nil {
+				return err
+			}
+			c.r.changed(ctx, ChangeGroup, groupID)
+			return c.r.record(ctx, Event{Type: EventGroupUpdated, GroupID: groupID, Group: updated})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergeInto - moves every holder of from to the privilege with toID and removes
+// from for good.
+func (r *MongoRepository) mergeInto(ctx context.Context, from *Privilege, toID string) error {
+	fromID := from.ID
+	users, groups, err := r.holders(ctx, fromID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.MigrateUserPrivileges(ctx); err != nil {
+		return err
+	}
+
+	_, err = r.mongoUser.UpdateMany(
+		ctx,
+		bson.M{"privilege_ids": fromID},
+		bson.M{"$addToSet": bson.M{"privilege_ids": toID}},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = r.mongoGroup.UpdateMany(
+		ctx,
+		bson.M{"privilege_ids": fromID},
+		bson.M{"$addToSet": bson.M{"privilege_ids": toID}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := r.removeFromUsers(ctx, fromID); err != nil {
+		return err
+	}
+	if err := r.removeFromGroups(ctx, fromID); err != nil {
+		return err
+	}
+
+	_, err = r.mongo.DeleteOne(ctx, bson.M{"id": fromID})
+	if err != nil {
+		return err
+	}
+
+	deletedAt := time.Now()
+	from.DeletedAt = &deletedAt
+	from.RemovedFromUsers = users
+	from.RemovedFromGroups = groups
+	r.changed(ctx, ChangePrivilege, fromID)
+	r.changed(ctx, ChangePrivilege, toID)
+	return r.record(ctx, Event{Type: EventPrivilegeDeleted, PrivilegeID: fromID, Privilege: from})
+}
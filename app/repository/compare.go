@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+)
+
+// PermissionDiff - a single permission of two compared privileges.
+type PermissionDiff struct {
+	Permission string `json:"permission"`
+	A          bool   `json:"a"`
+	B          bool   `json:"b"`
+	Different  bool   `json:"different"`
+}
+
+// Comparison - the permission by permission difference between two privileges.
+// Privileges do not inherit from each other, so their effective permissions
+// are their own.
+type Comparison struct {
+	A           *Privilege        `json:"a"`
+	B           *Privilege        `json:"b"`
+	Permissions []*PermissionDiff `json:"permissions"`
+	Equal       bool              `json:"equal"`
+}
+
+// Clone - copies the permissions of the privilege with sourceID into a new
+// privilege called name.
+func (r *MongoRepository) Clone(ctx context.Context, sourceID string, name string) (*Privilege, error) {
+	if sourceID == "" {
+		return nil, invalid("id", "ID is required")
+	}
+	source, err := r.Get(ctx, &Privilege{ID: sourceID})
+	if err != nil {
+		return nil, err
+	}
+
+	priv := &Privilege{
+		Name:                   name,
+		ViewAllUsers:           source.ViewAllUsers,
+		CreateUser:             source.CreateUser,
+		ManagePrivileges:       source.ManagePrivileges,
+		DeleteUser:             source.DeleteUser,
+		BlockUser:              source.BlockUser,
+		SendResetPasswordEmail: source.SendResetPasswordEmail,
+	}
+	if err := r.Create(ctx, priv); err != nil {
+		return nil, err
+	}
+
+	return priv, nil
+}
+
+// Compare - compares the privileges with aID and bID.
+func (r *MongoRepository) Compare(ctx context.Context, aID string, bID string) (*Comparison, error) {
+	if aID == "" || bID == "" {
+		return nil, invalid("id", "Two IDs are required")
+	}
+	a, err := r.Get(ctx, &Privilege{ID: aID})
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.Get(ctx, &Privilege{ID: bID})
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &Comparison{A: a, B: b, Permissions: []*PermissionDiff{}, Equal: true}
+	for _, name := range append([]string{"root"}, Permissions...) {
+		diff := &PermissionDiff{
+			Permission: name,
+			A:          a.Has(name),
+			B:          b.Has(name),
+		}
+		diff.Different = diff.A != diff.B
+		if diff.Different {
+			comparison.Equal = false
+		}
+		comparison.Permissions = append(comparison.Permissions, diff)
+	}
+
+	return comparison, nil
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+)
+
+// Permissions - names of the permissions a privilege can grant.
+var Permissions = []string{
+	"view_all_users",
+	"create_user",
+	"manage_privileges",
+	"delete_user",
+	"block_user",
+	"send_reset_password_email",
+}
+
+// Has - reports whether the privilege grants the permission with name.
+func (p *Privilege) Has(name string) bool {
+	switch name {
+	case "view_all_users":
+		return p.ViewAllUsers
+	case "create_user":
+		return p.CreateUser
+	case "manage_privileges":
+		return p.ManagePrivileges
+	case "delete_user":
+		return p.DeleteUser
+	case "block_user":
+		return p.BlockUser
+	case "send_reset_password_email":
+		return p.SendResetPasswordEmail
+	case "root":
+		return p.Root
+	}
+	return false
+}
+
+// Set - grants or takes away the permission with name.
+func (p *Privilege) Set(name string, value bool) {
+	switch name {
+	case "view_all_users":
+		p.ViewAllUsers = value
+	case "create_user":
+		p.CreateUser = value
+	case "manage_privileges":
+		p.ManagePrivileges = value
+	case "delete_user":
+		p.DeleteUser = value
+	case "block_user":
+		p.BlockUser = value
+	case "send_reset_password_email":
+		p.SendResetPasswordEmail = value
+	}
+}
+
+// merge - grants every permission of other.
+func (p *Privilege) merge(other *Privilege) {
+	p.ViewAllUsers = p.ViewAllUsers || other.ViewAllUsers
+	p.CreateUser = p.CreateUser || other.CreateUser
+	p.ManagePrivileges = p.ManagePrivileges || other.ManagePrivileges
+	p.DeleteUser = p.DeleteUser || other.DeleteUser
+	p.BlockUser = p.BlockUser || other.BlockUser
+	p.SendResetPasswordEmail = p.SendResetPasswordEmail || other.SendResetPasswordEmail
+	p.Root = p.Root || other.Root
+}
+
+// Grant - a permission held by a user and the privilege, and possibly group,
+// it comes from.
+type Grant struct {
+	Permission  string `json:"permission"`
+	PrivilegeID string `json:"privilege_id"`
+	GroupID     string `json:"group_id,omitempty"`
+}
+
+// EffectivePermissions - the union of the privileges a user holds directly and
+// through groups, together with where each permission comes from. Blocked
+// users hold no permissions. Authorization and the lockout guard both go by
+// these.
+type EffectivePermissions struct {
+	UserID    string     `json:"user_id"`
+	Blocked   bool       `json:"blocked"`
+	Privilege *Privilege `json:"privilege"`
+	Grants    []*Grant   `json:"grants"`
+}
+
+func (e *EffectivePermissions) add(priv *Privilege, groupID string) {
+	e.Privilege.merge(priv)
+	for _, name := range Permissions {
+		if priv.Has(name) {
+			e.Grants = append(e.Grants, &Grant{name, priv.ID, groupID})
+		}
+	}
+	if priv.Root {
+		e.Grants = append(e.Grants, &Grant{"root", priv.ID, groupID})
+	}
+}
+
+// resolve - the effective permissions of user from its own privilege ids and
+// the groups it is a member of. Ids missing from privileges, such as deleted
+// privileges, grant nothing.
+func resolve(user *User, groups []*Group, privileges map[string]*Privilege) *EffectivePermissions {
+	effective := &EffectivePermissions{
+		UserID:    user.ID,
+		Blocked:   user.Blocked,
+		Privilege: &Privilege{Name: "Effective"},
+		Grants:    []*Grant{},
+	}
+	if user.Blocked {
+		return effective
+	}
+
+	for _, id := range user.privileges() {
+		if priv, ok := privileges[id]; ok {
+			effective.add(priv, "")
+		}
+	}
+	for _, group := range groups {
+		for _, id := range group.PrivilegeIDs {
+			if priv, ok := privileges[id]; ok {
+				effective.add(priv, group.ID)
+			}
+		}
+	}
+
+	return effective
+}
+
+// GetEffectivePermissions - resolves the permissions of a user from its own
+// privileges and the privileges of every group it is a member of.
+func (r *MongoRepository) GetEffectivePermissions(ctx context.Context, userID string) (*EffectivePermissions, error) {
+	user, err := r.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := r.groupsOfUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	privileges := map[string]*Privilege{}
+	ids := append([]string{}, user.privileges()...)
+	for _, group := range groups {
+		ids = append(ids, group.PrivilegeIDs...)
+	}
+	for _, id := range ids {
+		if _, ok := privileges[id]; ok {
+			continue
+		}
+		if priv, err := r.Get(ctx, &Privilege{ID: id}); err == nil {
+			privileges[id] = priv
+		}
+	}
+
+	return resolve(user, groups, privileges), nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	privileges := map[string]*Privilege{
+		"manage": {ID: "manage", ManagePrivileges: true},
+		"view":   {ID: "view", ViewAllUsers: true},
+		"block":  {ID: "block", BlockUser: true},
+		"root":   {ID: "root", Root: true, ManagePrivileges: true},
+	}
+	groups := []*Group{
+		{ID: "admins", PrivilegeIDs: []string{"manage"}},
+		{ID: "support", PrivilegeIDs: []string{"block", "deleted"}},
+	}
+
+	tests := []struct {
+		name   string
+		user   *User
+		groups []*Group
+		// permissions expected, each with the group granting it or "" when held
+		// directly
+		grants map[string]string
+	}{
+		{"direct privilege", &User{ID: "u", PrivilegeIDs: []string{"view"}}, nil, map[string]string{"view_all_users": ""}},
+		{"union of direct privileges", &User{ID: "u", PrivilegeIDs: []string{"view", "block"}}, nil, map[string]string{"view_all_users": "", "block_user": ""}},
+		{"single field layout", &User{ID: "u", PrivilegeID: "manage"}, nil, map[string]string{"manage_privileges": ""}},
+		{"through a group", &User{ID: "u"}, groups[:1], map[string]string{"manage_privileges": "admins"}},
+		{"direct and through groups", &User{ID: "u", PrivilegeIDs: []string{"view"}}, groups, map[string]string{"view_all_users": "", "manage_privileges": "admins", "block_user": "support"}},
+		{"deleted privileges grant nothing", &User{ID: "u", PrivilegeIDs: []string{"deleted"}}, nil, map[string]string{}},
+		{"root", &User{ID: "u", PrivilegeIDs: []string{"root"}}, nil, map[string]string{"root": "", "manage_privileges": ""}},
+		{"blocked users hold nothing", &User{ID: "u", PrivilegeIDs: []string{"manage"}, Blocked: true}, groups, map[string]string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			effective := resolve(test.user, test.groups, privileges)
+
+			if effective.Blocked != test.user.Blocked {
+				t.Fatalf("expected blocked %v, got %v", test.user.Blocked, effective.Blocked)
+			}
+			if len(effective.Grants) != len(test.grants) {
+				t.Fatalf("expected %d grants, got %d", len(test.grants), len(effective.Grants))
+			}
+			for _, grant := range effective.Grants {
+				groupID, ok := test.grants[grant.Permission]
+				if !ok || groupID != grant.GroupID {
+					t.Fatalf("unexpected grant %+v", grant)
+				}
+			}
+			for _, name := range append(append([]string{}, Permissions...), "root") {
+				_, expected := test.grants[name]
+				if effective.Privilege.Has(name) != expected {
+					t.Fatalf("expected %s to be %v", name, expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// Kind - what went wrong in the repository, so callers can decide what to do
+// without matching on error messages.
+type Kind int
+
+// Kinds of repository errors.
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindValidation
+	KindConflict
+	KindProtected
+	KindPermissionDenied
+	KindUnavailable
+)
+
+// String - name of the kind.
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not_found"
+	case KindValidation:
+		return "validation"
+	case KindConflict:
+		return "conflict"
+	case KindProtected:
+		return "protected"
+	case KindPermissionDenied:
+		return "permission_denied"
+	case KindUnavailable:
+		return "unavailable"
+	}
+	return "internal"
+}
+
+// Error - a typed repository error. Message is kept identical to the messages
+// the service has always returned. Reason is a stable machine readable name of
+// the error and Field names the offending field of a validation error.
+type Error struct {
+	Kind    Kind
+	Reason  string
+	Field   string
+	Message string
+	Err     error
+}
+
+// Error - the message of the error.
+func (e *Error) Error() string {
+	if e.Message == "" && e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap - the underlying error, if any.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// notFound - wraps a failed lookup of what. Errors other than a missing
+// document are passed on untouched.
+func notFound(what string, err error) error {
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	return &Error{
+		Kind:   KindNotFound,
+		Reason: strings.ToUpper(what) + "_NOT_FOUND",
+		Err:    err,
+	}
+}
+
+// invalid - a validation error on field.
+func invalid(field string, message string) error {
+	return &Error{
+		Kind:    KindValidation,
+		Reason:  "INVALID_" + strings.ToUpper(field),
+		Field:   field,
+		Message: message,
+	}
+}
+
+// conflict - a change refused because of the current state.
+func conflict(reason string, message string) error {
+	return &Error{Kind: KindConflict, Reason: reason, Message: message}
+}
+
+// protected - a change to a privilege that can not be changed.
+func protected(reason string, message string) error {
+	return &Error{Kind: KindProtected, Reason: reason, Message: message}
+}
+
+// denied - an action the caller is not allowed to take.
+func denied(reason string, message string) error {
+	return &Error{Kind: KindPermissionDenied, Reason: reason, Message: message}
+}
+
+// AsError - the repository error in err. Errors not created by the
+// repository are classified where possible: a missing document is not found
+// and a database that can not be reached is unavailable. Otherwise nil.
+func AsError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var repoErr *Error
+	if errors.As(err, &repoErr) {
+		return repoErr
+	}
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &Error{Kind: KindNotFound, Reason: "NOT_FOUND", Err: err}
+	}
+	if databaseUnavailable(err) {
+		return &Error{Kind: KindUnavailable, Reason: "DATABASE_UNAVAILABLE", Err: err}
+	}
+
+	return nil
+}
+
+// IsNotFound - reports whether err means the thing looked up does not exist.
+func IsNotFound(err error) bool {
+	repoErr := AsError(err)
+	return repoErr != nil && repoErr.Kind == KindNotFound
+}
+
+// databaseUnavailable - reports whether err comes from mongo being
+// unreachable rather than from the request.
+func databaseUnavailable(err error) bool {
+	if errors.Is(err, topology.ErrServerSelectionTimeout) || errors.Is(err, mongo.ErrClientDisconnected) {
+		return true
+	}
+
+	var selection topology.ServerSelectionError
+	if errors.As(err, &selection) {
+		return true
+	}
+	var command mongo.CommandError
+	if errors.As(err, &command) {
+		return command.HasErrorLabel("NetworkError") || command.HasErrorLabel("RetryableWriteError")
+	}
+	var write mongo.WriteException
+	if errors.As(err, &write) {
+		return write.HasErrorLabel("NetworkError") || write.HasErrorLabel("RetryableWriteError")
+	}
+	return false
+}
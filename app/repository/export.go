@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Export - every privilege and group, as written by Export and read by Import.
+type Export struct {
+	Privileges []*Privilege `json:"privileges"`
+	Groups     []*Group     `json:"groups"`
+}
+
+// ImportResult - what Import did with the privileges and groups it was given.
+type ImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+}
+
+// Export - returns every privilege and group outside the trash.
+func (r *MongoRepository) Export(ctx context.Context) (*Export, error) {
+	privileges, err := r.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := r.GetAllGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Export{privileges, groups}, nil
+}
+
+// Import - creates or replaces the privileges and groups of data by id, using
+// the same validation as Create and CreateGroup. Root and default privileges
+// are only created when missing. Replacing a privilege that is in the trash,
+// which brings it back without its former holders, or replacing a privilege or
+// group in a way that would leave too few privilege administrators is refused
+// unless force is set. Everything is imported in a single transaction when
+// events are recorded.
+func (r *MongoRepository) Import(ctx context.Context, data *Export, force bool) (*ImportResult, error) {
+	var result *ImportResult
+	err := r.transact(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = r.importData(ctx, data, force)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// importData - the part of Import done in its transaction.
+func (r *MongoRepository) importData(ctx context.Context, data *Export, force bool) (*ImportResult, error) {
+	result := &ImportResult{}
+
+	for _, priv := range data.Privileges {
+		if priv.Root || priv.Default {
+			special := r.GetDefault
+			if priv.Root {
+				special = r.GetRoot
+			}
+			if _, err := special(ctx); err == nil {
+				result.Skipped++
+				continue
+			}
+		}
+
+		if err := priv.validate("create"); err != nil {
+			return nil, err
+		}
+		if priv.CreatedAt.IsZero() {
+			priv.CreatedAt = time.Now()
+		}
+		priv.UpdatedAt = time.Now()
+		priv.DeletedAt = nil
+		priv.RemovedFromUsers = nil
+		priv.RemovedFromGroups = nil
+
+		// trashed privileges keep their id, so they are replaced rather than
+		// inserted again
+		current := &Privilege{}
+		err := r.mongo.FindOne(ctx, bson.M{"id": priv.ID}).Decode(current)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			if _, err := r.mongo.InsertOne(ctx, priv); err != nil {
+				return nil, err
+			}
+			r.changed(ctx, ChangePrivilege, priv.ID)
+			if err := r.record(ctx, Event{Type: EventPrivilegeCreated, PrivilegeID: priv.ID, Privilege: priv}); err != nil {
+				return nil, err
+			}
+			result.Created++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if current.Root || current.Default {
+			result.Skipped++
+			continue
+		}
+		if priv.Root || priv.Default {
+			return nil, protected("ROOT_PRIVILEGE", "Cannot turn an existing privilege into a root privilege")
+		}
+		eventType := EventPrivilegeUpdated
+		if current.DeletedAt != nil {
+			if !force {
+				return nil, conflict("PRIVILEGE_DELETED", fmt.Sprintf("Privilege %s is in the trash, restore it first or import with force", current.ID))
+			}
+			eventType = EventPrivilegeRestored
+		} else if current.ManagePrivileges && !priv.ManagePrivileges {
+			if err := r.guardLockout(ctx, "import", "privilege "+current.Name, grantRemoval{privilegeID: current.ID}, force); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := r.mongo.ReplaceOne(ctx, bson.M{"id": priv.ID}, priv); err != nil {
+			return nil, err
+		}
+		r.changed(ctx, ChangePrivilege, priv.ID)
+		if err := r.record(ctx, Event{Type: eventType, PrivilegeID: priv.ID, Privilege: priv}); err != nil {
+			return nil, err
+		}
+		result.Updated++
+	}
+
+	for _, group := range data.Groups {
+		if group.CreatedAt.IsZero() {
+			group.CreatedAt = time.Now()
+		}
+		group.prepare("update")
+		if err := r.validateGroup(ctx, group); err != nil {
+			return nil, err
+		}
+		for _, member := range group.Members {
+			if _, err := r.getUser(ctx, member); err != nil {
+				return nil, err
+			}
+		}
+
+		current, err := r.GetGroup(ctx, group.ID)
+		if err != nil && !IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil && r.grantsManage(ctx, current.PrivilegeIDs) && !r.keepsAdmins(ctx, current, group) {
+			if err := r.guardLockout(ctx, "import", "group "+current.Name, grantRemoval{groupID: current.ID}, force); err != nil {
+				return nil, err
+			}
+		}
+
+		replaced, err := r.mongoGroup.ReplaceOne(ctx, bson.M{"id": group.ID}, group, options.Replace().SetUpsert(true))
+		if err != nil {
+			return nil, err
+		}
+		eventType := EventGroupUpdated
+		if replaced.UpsertedCount > 0 {
+			eventType = EventGroupCreated
+			result.Created++
+		} else {
+			result.Updated++
+		}
+		r.changed(ctx, ChangeGroup, group.ID)
+		if err := r.record(ctx, Event{Type: eventType, GroupID: group.ID, Group: group}); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// keepsAdmins - reports whether replacing current with group leaves every
+// member of current able to manage privileges through the group.
+func (r *MongoRepository) keepsAdmins(ctx context.Context, current *Group, group *Group) bool {
+	if !r.grantsManage(ctx, group.PrivilegeIDs) {
+		return false
+	}
+	members := map[string]bool{}
+	for _, member := range group.Members {
+		members[member] = true
+	}
+	for _, member := range current.Members {
+		if !members[member] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,325 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Group - struct. Every member of a group holds the privileges of the group.
+type Group struct {
+	ID           string    `bson:"id" json:"id"`
+	Name         string    `bson:"name" json:"name"`
+	Members      []string  `bson:"members" json:"members"`
+	PrivilegeIDs []string  `bson:"privilege_ids" json:"privilege_ids"`
+	CreatedAt    time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+func (g *Group) prepare(action string) {
+	g.Name = strings.Trim(g.Name, " ")
+	if g.Members == nil {
+		g.Members = []string{}
+	}
+	if g.PrivilegeIDs == nil {
+		g.PrivilegeIDs = []string{}
+	}
+	switch strings.ToLower(action) {
+	case "create":
+		g.CreatedAt = time.Now()
+		g.UpdatedAt = time.Now()
+	case "update":
+		g.UpdatedAt = time.Now()
+	}
+}
+
+// validateGroup - validates a group and the privileges it refers to.
+func (r *MongoRepository) validateGroup(ctx context.Context, g *Group) error {
+	if g.ID == "" {
+		return invalid("id", "ID is required")
+	}
+	if g.Name == "" {
+		return invalid("name", "Name is required")
+	}
+	for _, id := range g.PrivilegeIDs {
+		priv, err := r.Get(ctx, &Privilege{ID: id})
+		if err != nil {
+			return err
+		}
+		if priv.Root {
+			return protected("ROOT_PRIVILEGE", "Cannot grant root privilege through a group")
+		}
+	}
+	return nil
+}
+
+// grantsManage - reports whether any of the privileges with ids can manage
+// privileges.
+func (r *MongoRepository) grantsManage(ctx context.Context, ids []string) bool {
+	for _, id := range ids {
+		if priv, err := r.Get(ctx, &Privilege{ID: id}); err == nil && priv.ManagePrivileges {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateGroup - creates a new group.
+func (r *MongoRepository) CreateGroup(ctx context.Context, group *Group) error {
+	group.ID = uuid.NewV4().String()
+
+	group.prepare("create")
+
+	if err := r.validateGroup(ctx, group); err != nil {
+		return err
+	}
+	for _, member := range group.Members {
+		if _, err := r.getUser(ctx, member); err != nil {
+			return err
+		}
+	}
+
+	return r.transact(ctx, func(ctx context.Context) error {
+		if _, err := r.mongoGroup.InsertOne(ctx, group); err != nil {
+			return err
+		}
+		r.changed(ctx, ChangeGroup, group.ID)
+		return r.record(ctx, Event{Type: EventGroupCreated, GroupID: group.ID, Group: group})
+	})
+}
+
+// UpdateGroup - updates the name and privileges of an existing group by id.
+// If the group stops granting the ability to manage privileges and that would
+// leave too few privilege administrators it is refused unless force is set.
+func (r *MongoRepository) UpdateGroup(ctx context.Context, group *Group, force bool) error {
+	group.prepare("update")
+
+	if err := r.validateGroup(ctx, group); err != nil {
+		return err
+	}
+
+	return r.transact(ctx, func(ctx context.Context) error {
+		return r.updateGroup(ctx, group, force)
+	})
+}
+
+// updateGroup - the part of UpdateGroup done in its transaction.
+func (r *MongoRepository) updateGroup(ctx context.Context, group *Group, force bool) error {
+	current, err := r.GetGroup(ctx, group.ID)
+	if err != nil {
+		return err
+	}
+
+	if !r.grantsManage(ctx, group.PrivilegeIDs) {
+		if err := r.guardLockout(ctx, "update", "group "+current.Name, grantRemoval{groupID: current.ID}, force); err != nil {
+			return err
+		}
+	}
+
+	updateGroup := bson.M{
+		"$set": bson.M{
+			"name":          group.Name,
+			"privilege_ids": group.PrivilegeIDs,
+			"updated_at":    group.UpdatedAt,
+		},
+	}
+
+	_, err = r.mongoGroup.UpdateOne(
+		ctx,
+		bson.M{"id": group.ID},
+		updateGroup,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	updated, err := r.GetGroup(ctx, group.ID)
+	if err != nil {
+		return err
+	}
+	r.changed(ctx, ChangeGroup, updated.ID)
+	return r.record(ctx, Event{Type: EventGroupUpdated, GroupID: updated.ID, Group: updated})
+}
+
+// GetGroup - finds single group using the group's id.
+func (r *MongoRepository) GetGroup(ctx context.Context, groupID string) (*Group, error) {
+	group := Group{}
+
+	if err := r.mongoGroup.FindOne(ctx, bson.M{"id": groupID}).Decode(&group); err != nil {
+		return nil, notFound("group", err)
+	}
+
+	return &group, nil
+}
+
+// GetAllGroups - returns every group in the system.
+func (r *MongoRepository) GetAllGroups(ctx context.Context) ([]*Group, error) {
+	return r.findGroups(ctx, bson.M{})
+}
+
+// DeleteGroup - deletes a given group by id. Members keep their own
+// privileges. If the delete would leave too few privilege administrators it is
+// refused unless force is set.
+func (r *MongoRepository) DeleteGroup(ctx context.Context, groupID string, force bool) error {
+	return r.transact(ctx, func(ctx context.Context) error {
+		return r.deleteGroup(ctx, groupID, force)
+	})
+}
+
+// deleteGroup - the part of DeleteGroup done in its transaction.
+func (r *MongoRepository) deleteGroup(ctx context.Context, groupID string, force bool) error {
+	current, err := r.GetGroup(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	if err := r.guardLockout(ctx, "delete", "group "+current.Name, grantRemoval{groupID: current.ID}, force); err != nil {
+		return err
+	}
+
+	_, err = r.mongoGroup.DeleteOne(ctx, bson.M{"id": current.ID})
+	if err != nil {
+		return err
+	}
+
+	r.changed(ctx, ChangeGroup, current.ID)
+	return r.record(ctx, Event{Type: EventGroupDeleted, GroupID: current.ID, Group: current})
+}
+
+// AddGroupMember - adds a user to a group.
+func (r *MongoRepository) AddGroupMember(ctx context.Context, groupID string, userID string) error {
+	return r.transact(ctx, func(ctx context.Context) error {
+		return r.addGroupMember(ctx, groupID, userID)
+	})
+}
+
+// addGroupMember - the part of AddGroupMember done in its transaction.
+func (r *MongoRepository) addGroupMember(ctx context.Context, groupID string, userID string) error {
+	group, err := r.GetGroup(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	user, err := r.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.mongoGroup.UpdateOne(
+		ctx,
+		bson.M{"id": group.ID},
+		bson.M{
+			"$addToSet": bson.M{"members": user.ID},
+			"$set":      bson.M{"updated_at": time.Now()},
+		},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	r.changed(ctx, ChangeGroup, group.ID)
+	return r.record(ctx, Event{Type: EventGroupMemberAdded, GroupID: group.ID, UserID: user.ID})
+}
+
+// RemoveGroupMember - removes a user from a group. If the user is one of the
+// last administrators allowed to manage privileges it is refused unless force
+// is set.
+func (r *MongoRepository) RemoveGroupMember(ctx context.Context, groupID string, userID string, force bool) error {
+	return r.transact(ctx, func(ctx context.Context) error {
+		return r.removeGroupMember(ctx, groupID, userID, force)
+	})
+}
+
+// removeGroupMember - the part of RemoveGroupMember done in its transaction.
+func (r *MongoRepository) removeGroupMember(ctx context.Context, groupID string, userID string, force bool) error {
+	group, err := r.GetGroup(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, member := range group.Members {
+		if member == userID {
+			found = true
+		}
+	}
+	if !found {
+		return conflict("NOT_A_MEMBER", "User is not a member of group")
+	}
+
+	if err := r.guardLockout(ctx, "remove", "user "+userID+" from group "+group.Name, grantRemoval{groupID: group.ID, userID: userID}, force); err != nil {
+		return err
+	}
+
+	_, err = r.mongoGroup.UpdateOne(
+		ctx,
+		bson.M{"id": group.ID},
+		bson.M{
+			"$pull": bson.M{"members": userID},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	r.changed(ctx, ChangeGroup, group.ID)
+	return r.record(ctx, Event{Type: EventGroupMemberRemoved, GroupID: group.ID, UserID: userID})
+}
+
+// groupsWithPrivileges - returns groups granting any of the given privileges.
+func (r *MongoRepository) groupsWithPrivileges(ctx context.Context, ids []string) ([]*Group, error) {
+	return r.findGroups(ctx, bson.M{"privilege_ids": bson.M{"$in": ids}})
+}
+
+// groupsOfUser - returns the groups a user is a member of.
+func (r *MongoRepository) groupsOfUser(ctx context.Context, userID string) ([]*Group, error) {
+	return r.findGroups(ctx, bson.M{"members": userID})
+}
+
+func (r *MongoRepository) findGroups(ctx context.Context, filter bson.M) ([]*Group, error) {
+	groupsReturn := []*Group{}
+
+	cursor, err := r.mongoGroup.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var tempGroup Group
+		if err := cursor.Decode(&tempGroup); err != nil {
+			return nil, err
+		}
+		groupsReturn = append(groupsReturn, &tempGroup)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return groupsReturn, nil
+}
+
+// removeFromGroups - takes the privilege with privilegeID away from every
+// group granting it.
+func (r *MongoRepository) removeFromGroups(ctx context.Context, privilegeID string) error {
+	_, err := r.mongoGroup.UpdateMany(
+		ctx,
+		bson.M{"privilege_ids": privilegeID},
+		bson.M{
+			"$pull": bson.M{"privilege_ids": privilegeID},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
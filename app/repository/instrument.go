@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// Hook - called as an operation of an instrumented repository starts. It may
+// return a derived ctx for the operation and returns a func called with the
+// error the operation ended with.
+type Hook func(ctx context.Context, operation string) (context.Context, func(err error))
+
+// instrumented - runs hooks around every operation of the wrapped repository.
+type instrumented struct {
+	next  Repository
+	hooks []Hook
+}
+
+// Instrument - returns next running hooks around every operation, whatever
+// backend next is. Hooks start in the order given and end in reverse.
+func Instrument(next Repository, hooks ...Hook) Repository {
+	return &instrumented{next, hooks}
+}
+
+// start - starts operation in every hook and returns the ctx to run it with
+// and the func ending it.
+func (r *instrumented) start(ctx context.Context, operation string) (context.Context, func(err error)) {
+	dones := make([]func(err error), len(r.hooks))
+	for i, hook := range r.hooks {
+		ctx, dones[i] = hook(ctx, operation)
+	}
+	return ctx, func(err error) {
+		for i := len(dones) - 1; i >= 0; i-- {
+			dones[i](err)
+		}
+	}
+}
+
+// Create - see Repository.
+func (r *instrumented) Create(ctx context.Context, priv *Privilege) error {
+	ctx, done := r.start(ctx, "Create")
+	err := r.next.Create(ctx, priv)
+	done(err)
+	return err
+}
+
+// CreateDefault - see Repository.
+func (r *instrumented) CreateDefault(ctx context.Context) error {
+	ctx, done := r.start(ctx, "CreateDefault")
+	err := r.next.CreateDefault(ctx)
+	done(err)
+	return err
+}
+
+// Update - see Repository.
+func (r *instrumented) Update(ctx context.Context, priv *Privilege, force bool) error {
+	ctx, done := r.start(ctx, "Update")
+	err := r.next.Update(ctx, priv, force)
+	done(err)
+	return err
+}
+
+// Get - see Repository.
+func (r *instrumented) Get(ctx context.Context, priv *Privilege) (*Privilege, error) {
+	ctx, done := r.start(ctx, "Get")
+	result, err := r.next.Get(ctx, priv)
+	done(err)
+	return result, err
+}
+
+// GetDefault - see Repository.
+func (r *instrumented) GetDefault(ctx context.Context) (*Privilege, error) {
+	ctx, done := r.start(ctx, "GetDefault")
+	result, err := r.next.GetDefault(ctx)
+	done(err)
+	return result, err
+}
+
+// GetRoot - see Repository.
+func (r *instrumented) GetRoot(ctx context.Context) (*Privilege, error) {
+	ctx, done := r.start(ctx, "GetRoot")
+	result, err := r.next.GetRoot(ctx)
+	done(err)
+	return result, err
+}
+
+// GetAll - see Repository.
+func (r *instrumented) GetAll(ctx context.Context) ([]*Privilege, error) {
+	ctx, done := r.start(ctx, "GetAll")
+	result, err := r.next.GetAll(ctx)
+	done(err)
+	return result, err
+}
+
+// Delete - see Repository.
+func (r *instrumented) Delete(ctx context.Context, priv *Privilege, force bool) error {
+	ctx, done := r.start(ctx, "Delete")
+	err := r.next.Delete(ctx, priv, force)
+	done(err)
+	return err
+}
+
+// Clone - see Repository.
+func (r *instrumented) Clone(ctx context.Context, sourceID string, name string) (*Privilege, error) {
+	ctx, done := r.start(ctx, "Clone")
+	result, err := r.next.Clone(ctx, sourceID, name)
+	done(err)
+	return result, err
+}
+
+// Compare - see Repository.
+func (r *instrumented) Compare(ctx context.Context, aID string, bID string) (*Comparison, error) {
+	ctx, done := r.start(ctx, "Compare")
+	result, err := r.next.Compare(ctx, aID, bID)
+	done(err)
+	return result, err
+}
+
+// GetDeleted - see Repository.
+func (r *instrumented) GetDeleted(ctx context.Context) ([]*Privilege, error) {
+	ctx, done := r.start(ctx, "GetDeleted")
+	result, err := r.next.GetDeleted(ctx)
+	done(err)
+	return result, err
+}
+
+// Restore - see Repository.
+func (r *instrumented) Restore(ctx context.Context, privilegeID string, restoreHolders bool) (*Privilege, error) {
+	ctx, done := r.start(ctx, "Restore")
+	result, err := r.next.Restore(ctx, privilegeID, restoreHolders)
+	done(err)
+	return result, err
+}
+
+// PurgeDeleted - see Repository.
+func (r *instrumented) PurgeDeleted(ctx context.Context, olderThan time.Time) (int64, error) {
+	ctx, done := r.start(ctx, "PurgeDeleted")
+	result, err := r.next.PurgeDeleted(ctx, olderThan)
+	done(err)
+	return result, err
+}
+
+// Check - see Repository.
+func (r *instrumented) Check(ctx context.Context, repair bool) (*CheckReport, error) {
+	ctx, done := r.start(ctx, "Check")
+	result, err := r.next.Check(ctx, repair)
+	done(err)
+	return result, err
+}
+
+// Export - see Repository.
+func (r *instrumented) Export(ctx context.Context) (*Export, error) {
+	ctx, done := r.start(ctx, "Export")
+	result, err := r.next.Export(ctx)
+	done(err)
+	return result, err
+}
+
+// Import - see Repository.
+func (r *instrumented) Import(ctx context.Context, data *Export, force bool) (*ImportResult, error) {
+	ctx, done := r.start(ctx, "Import")
+	result, err := r.next.Import(ctx, data, force)
+	done(err)
+	return result, err
+}
+
+// IssueBreakGlass - see Repository.
+func (r *instrumented) IssueBreakGlass(ctx context.Context, ttl time.Duration, maxUses int, reason string) (string, *BreakGlass, error) {
+	ctx, done := r.start(ctx, "IssueBreakGlass")
+	result, total, err := r.next.IssueBreakGlass(ctx, ttl, maxUses, reason)
+	done(err)
+	return result, total, err
+}
+
+// UseBreakGlass - see Repository.
+func (r *instrumented) UseBreakGlass(ctx context.Context, token string) (*BreakGlass, error) {
+	ctx, done := r.start(ctx, "UseBreakGlass")
+	result, err := r.next.UseBreakGlass(ctx, token)
+	done(err)
+	return result, err
+}
+
+// RevokeBreakGlass - see Repository.
+func (r *instrumented) RevokeBreakGlass(ctx context.Context) (int64, error) {
+	ctx, done := r.start(ctx, "RevokeBreakGlass")
+	result, err := r.next.RevokeBreakGlass(ctx)
+	done(err)
+	return result, err
+}
+
+// AssignPrivilege - see Repository.
+func (r *instrumented) AssignPrivilege(ctx context.Context, userID string, privilegeID string) error {
+	ctx, done := r.start(ctx, "AssignPrivilege")
+	err := r.next.AssignPrivilege(ctx, userID, privilegeID)
+	done(err)
+	return err
+}
+
+// RevokePrivilege - see Repository.
+func (r *instrumented) RevokePrivilege(ctx context.Context, userID string, privilegeID string, force bool) error {
+	ctx, done := r.start(ctx, "RevokePrivilege")
+	err := r.next.RevokePrivilege(ctx, userID, privilegeID, force)
+	done(err)
+	return err
+}
+
+// GetEffectivePermissions - see Repository.
+func (r *instrumented) GetEffectivePermissions(ctx context.Context, userID string) (*EffectivePermissions, error) {
+	ctx, done := r.start(ctx, "GetEffectivePermissions")
+	result, err := r.next.GetEffectivePermissions(ctx, userID)
+	done(err)
+	return result, err
+}
+
+// ListUsersWithPrivilege - see Repository.
+func (r *instrumented) ListUsersWithPrivilege(ctx context.Context, privilegeID string, offset int64, limit int64) ([]*User, int64, error) {
+	ctx, done := r.start(ctx, "ListUsersWithPrivilege")
+	result, total, err := r.next.ListUsersWithPrivilege(ctx, privilegeID, offset, limit)
+	done(err)
+	return result, total, err
+}
+
+// CountUsersPerPrivilege - see Repository.
+func (r *instrumented) CountUsersPerPrivilege(ctx context.Context) ([]*PrivilegeCount, error) {
+	ctx, done := r.start(ctx, "CountUsersPerPrivilege")
+	result, err := r.next.CountUsersPerPrivilege(ctx)
+	done(err)
+	return result, err
+}
+
+// MigrateUserPrivileges - see Repository.
+func (r *instrumented) MigrateUserPrivileges(ctx context.Context) (int64, error) {
+	ctx, done := r.start(ctx, "MigrateUserPrivileges")
+	result, err := r.next.MigrateUserPrivileges(ctx)
+	done(err)
+	return result, err
+}
+
+// CreateGroup - see Repository.
+func (r *instrumented) CreateGroup(ctx context.Context, group *Group) error {
+	ctx, done := r.start(ctx, "CreateGroup")
+	err := r.next.CreateGroup(ctx, group)
+	done(err)
+	return err
+}
+
+// UpdateGroup - see Repository.
+func (r *instrumented) UpdateGroup(ctx context.Context, group *Group, force bool) error {
+	ctx, done := r.start(ctx, "UpdateGroup")
+	err := r.next.UpdateGroup(ctx, group, force)
+	done(err)
+	return err
+}
+
+// GetGroup - see Repository.
+func (r *instrumented) GetGroup(ctx context.Context, groupID string) (*Group, error) {
+	ctx, done := r.start(ctx, "GetGroup")
+	result, err := r.next.GetGroup(ctx, groupID)
+	done(err)
+	return result, err
+}
+
+// GetAllGroups - see Repository.
+func (r *instrumented) GetAllGroups(ctx context.Context) ([]*Group, error) {
+	ctx, done := r.start(ctx, "GetAllGroups")
+	result, err := r.next.GetAllGroups(ctx)
+	done(err)
+	return result, err
+}
+
+// DeleteGroup - see Repository.
+func (r *instrumented) DeleteGroup(ctx context.Context, groupID string, force bool) error {
+	ctx, done := r.start(ctx, "DeleteGroup")
+	err := r.next.DeleteGroup(ctx, groupID, force)
+	done(err)
+	return err
+}
+
+// AddGroupMember - see Repository.
+func (r *instrumented) AddGroupMember(ctx context.Context, groupID string, userID string) error {
+	ctx, done := r.start(ctx, "AddGroupMember")
+	err := r.next.AddGroupMember(ctx, groupID, userID)
+	done(err)
+	return err
+}
+
+// RemoveGroupMember - see Repository.
+func (r *instrumented) RemoveGroupMember(ctx context.Context, groupID string, userID string, force bool) error {
+	ctx, done := r.start(ctx, "RemoveGroupMember")
+	err := r.next.RemoveGroupMember(ctx, groupID, userID, force)
+	done(err)
+	return err
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// grantRemoval - describes a grant of the manage privileges permission that is
+// about to go away. Without userID the privilege or group stops granting it to
+// everyone, with userID only that user loses it.
+type grantRemoval struct {
+	privilegeID string
+	groupID     string
+	userID      string
+}
+
+// activeUsers - filter matching users that are not blocked.
+func activeUsers(filter bson.M) bson.M {
+	filter["blocked"] = bson.M{"$ne": true}
+	return filter
+}
+
+// countAdmins - counts active users that can manage privileges through a
+// non-root privilege, either directly or through a group, once removal is
+// applied. Users are resolved the way GetEffectivePermissions resolves them,
+// which is what authorization goes by.
+func (r *MongoRepository) countAdmins(ctx context.Context, removal grantRemoval) (int64, error) {
+	cursor, err := r.mongo.Find(ctx, notDeleted(bson.M{
+		"manage_privileges": true,
+		"root":              bson.M{"$ne": true},
+	}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	adminPrivileges := map[string]*Privilege{}
+	ids := []string{}
+	for cursor.Next(ctx) {
+		var tempPriv Privilege
+		if err := cursor.Decode(&tempPriv); err != nil {
+			return 0, err
+		}
+		if removal.userID == "" && tempPriv.ID == removal.privilegeID {
+			continue
+		}
+		adminPrivileges[tempPriv.ID] = &tempPriv
+		ids = append(ids, tempPriv.ID)
+	}
+	if err := cursor.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	groups, err := r.groupsWithPrivileges(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	adminGroups := map[string][]*Group{}
+	members := []string{}
+	for _, group := range groups {
+		if removal.userID == "" && group.ID == removal.groupID {
+			continue
+		}
+		for _, member := range group.Members {
+			if member == removal.userID && group.ID == removal.groupID {
+				continue
+			}
+			adminGroups[member] = append(adminGroups[member], group)
+			members = append(members, member)
+		}
+	}
+
+	usersCursor, err := r.mongoUser.Find(ctx, activeUsers(bson.M{"$or": []bson.M{
+		holding(ids...),
+		{"id": bson.M{"$in": members}},
+	}}))
+	if err != nil {
+		return 0, err
+	}
+	defer usersCursor.Close(ctx)
+
+	var admins int64
+	for usersCursor.Next(ctx) {
+		var tempUser User
+		if err := usersCursor.Decode(&tempUser); err != nil {
+			return 0, err
+		}
+		if tempUser.ID == removal.userID && removal.privilegeID != "" {
+			tempUser.PrivilegeIDs = without(tempUser.privileges(), removal.privilegeID)
+			tempUser.PrivilegeID = ""
+		}
+		if resolve(&tempUser, adminGroups[tempUser.ID], adminPrivileges).Privilege.ManagePrivileges {
+			admins++
+		}
+	}
+	if err := usersCursor.Err(); err != nil {
+		return 0, err
+	}
+
+	return admins, nil
+}
+
+// without - ids except id.
+func without(ids []string, id string) []string {
+	rest := []string{}
+	for _, other := range ids {
+		if other != id {
+			rest = append(rest, other)
+		}
+	}
+	return rest
+}
+
+// floorAdmins - the minimum of administrators actually enforced, never less
+// than one.
+func floorAdmins(minAdmins int64) int64 {
+	if minAdmins < 1 {
+		return 1
+	}
+	return minAdmins
+}
+
+// leavesEnough - reports whether a change taking the number of administrators
+// from before to after may go ahead: it does not lower the number or leaves at
+// least the minimum.
+func leavesEnough(before int64, after int64, minAdmins int64) bool {
+	return after >= before || after >= floorAdmins(minAdmins)
+}
+
+// guardID - id of the document in the guard collection every guarded change
+// writes to.
+const guardID = "admins"
+
+// touchGuard - writes to the guard document, so two transactions guarding
+// changes at the same time conflict and one of them is retried, counting the
+// administrators again once the other committed. Otherwise both could count
+// before either wrote and together leave too few administrators.
+func (r *MongoRepository) touchGuard(ctx context.Context) error {
+	if r.mongoGuard == nil {
+		return nil
+	}
+
+	_, err := r.mongoGuard.UpdateOne(
+		ctx,
+		bson.M{"id": guardID},
+		bson.M{
+			"$inc": bson.M{"version": 1},
+			"$set": bson.M{"updated_at": time.Now()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// guardLockout - refuses a change that takes away the ability to manage
+// privileges, if that would leave fewer than the configured minimum of
+// non-root administrators. A forced change is allowed and audited once it
+// succeeded. Must run in the transaction of the change, see touchGuard.
+func (r *MongoRepository) guardLockout(ctx context.Context, action string, subject string, removal grantRemoval, force bool) error {
+	if err := r.touchGuard(ctx); err != nil {
+		return err
+	}
+
+	before, err := r.countAdmins(ctx, grantRemoval{})
+	if err != nil {
+		return err
+	}
+	after, err := r.countAdmins(ctx, removal)
+	if err != nil {
+		return err
+	}
+	if leavesEnough(before, after, r.minAdmins) {
+		return nil
+	}
+
+	msg := fmt.Sprintf("Cannot %s %s: it would leave %d users able to manage privileges, minimum is %d", action, subject, after, floorAdmins(r.minAdmins))
+	if !force {
+		return conflict("LOCKOUT", msg)
+	}
+
+	return r.auditAfter(ctx, &AuditEntry{
+		Action:      action,
+		PrivilegeID: removal.privilegeID,
+		GroupID:     removal.groupID,
+		UserID:      removal.userID,
+		Forced:      true,
+		Message:     msg,
+	})
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLeavesEnough(t *testing.T) {
+	tests := []struct {
+		name      string
+		before    int64
+		after     int64
+		minAdmins int64
+		ok        bool
+	}{
+		{"keeps the minimum", 3, 2, 2, true},
+		{"drops below the minimum", 2, 1, 2, false},
+		{"removes the last administrator", 1, 0, 1, false},
+		{"minimum of zero still keeps one", 1, 0, 0, false},
+		{"negative minimum still keeps one", 2, 0, -1, false},
+		{"minimum of zero allows dropping to one", 2, 1, 0, true},
+		{"already below the minimum and unchanged", 1, 1, 3, true},
+		{"already below the minimum and lowered", 2, 1, 3, false},
+		{"no administrators to lose", 0, 0, 1, true},
+		{"adds administrators", 1, 2, 5, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if ok := leavesEnough(test.before, test.after, test.minAdmins); ok != test.ok {
+				t.Fatalf("leavesEnough(%d, %d, %d) = %v, expected %v", test.before, test.after, test.minAdmins, ok, test.ok)
+			}
+		})
+	}
+}
+
+func TestFloorAdmins(t *testing.T) {
+	tests := []struct {
+		minAdmins int64
+		floor     int64
+	}{
+		{-1, 1},
+		{0, 1},
+		{1, 1},
+		{3, 3},
+	}
+
+	for _, test := range tests {
+		if floor := floorAdmins(test.minAdmins); floor != test.floor {
+			t.Fatalf("floorAdmins(%d) = %d, expected %d", test.minAdmins, floor, test.floor)
+		}
+	}
+}
+
+func TestWithout(t *testing.T) {
+	tests := []struct {
+		ids  []string
+		id   string
+		rest []string
+	}{
+		{[]string{"a", "b", "c"}, "b", []string{"a", "c"}},
+		{[]string{"a", "b", "a"}, "a", []string{"b"}},
+		{[]string{"a"}, "a", []string{}},
+		{[]string{"a"}, "b", []string{"a"}},
+		{nil, "a", []string{}},
+	}
+
+	for _, test := range tests {
+		if rest := without(test.ids, test.id); !reflect.DeepEqual(rest, test.rest) {
+			t.Fatalf("without(%v, %s) = %v, expected %v", test.ids, test.id, rest, test.rest)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Types of events recorded in the outbox.
+const (
+	EventPrivilegeCreated   = "privilege.created"
+	EventPrivilegeUpdated   = "privilege.updated"
+	EventPrivilegeDeleted   = "privilege.deleted"
+	EventPrivilegeRestored  = "privilege.restored"
+	EventPrivilegePurged    = "privilege.purged"
+	EventPrivilegeAssigned  = "privilege.assigned"
+	EventPrivilegeRevoked   = "privilege.revoked"
+	EventGroupCreated       = "group.created"
+	EventGroupUpdated       = "group.updated"
+	EventGroupDeleted       = "group.deleted"
+	EventGroupMemberAdded   = "group.member_added"
+	EventGroupMemberRemoved = "group.member_removed"
+)
+
+// Event - a change other services are told about. Privilege and group events
+// carry a snapshot of what changed, assignment and membership events the user
+// and, for assignments, every privilege the user holds afterwards. Events may
+// be delivered more than once and out of order after retries, consumers
+// should skip ids they have seen and snapshots older than the one they hold.
+type Event struct {
+	ID           string     `bson:"id" json:"id"`
+	Type         string     `bson:"type" json:"type"`
+	PrivilegeID  string     `bson:"privilege_id,omitempty" json:"privilege_id,omitempty"`
+	Privilege    *Privilege `bson:"privilege,omitempty" json:"privilege,omitempty"`
+	GroupID      string     `bson:"group_id,omitempty" json:"group_id,omitempty"`
+	Group        *Group     `bson:"group,omitempty" json:"group,omitempty"`
+	UserID       string     `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	PrivilegeIDs []string   `bson:"privilege_ids,omitempty" json:"privilege_ids,omitempty"`
+	Actor        string     `bson:"actor,omitempty" json:"actor,omitempty"`
+	OccurredAt   time.Time  `bson:"occurred_at" json:"occurred_at"`
+}
+
+// OutboxEntry - an event waiting in the outbox along with how its delivery
+// went so far.
+type OutboxEntry struct {
+	Event       `bson:",inline"`
+	Attempts    int        `bson:"attempts" json:"attempts"`
+	NextAttempt time.Time  `bson:"next_attempt" json:"next_attempt"`
+	LockedUntil time.Time  `bson:"locked_until" json:"-"`
+	LastError   string     `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	PublishedAt *time.Time `bson:"published_at,omitempty" json:"published_at,omitempty"`
+	DeadAt      *time.Time `bson:"dead_at,omitempty" json:"dead_at,omitempty"`
+}
+
+// transact - runs fn in a transaction when events are recorded or lockouts
+// guarded, so a change, its events and its audit entries are stored together
+// or not at all. Transactions need mongo to run as a replica set. Without an
+// outbox and a guard fn runs as it is. Audit entries kept by auditAfter are
+// written once fn succeeds. Listeners are told about changes once fn is done,
+// and only if the transaction commits. Within a transaction fn joins it.
+func (r *MongoRepository) transact(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(pendingKey{}).(*pendingChanges); ok {
+		return fn(ctx)
+	}
+	pending := &pendingChanges{}
+	ctx = context.WithValue(ctx, pendingKey{}, pending)
+
+	if r.mongoOutbox == nil && r.mongoGuard == nil {
+		err := fn(ctx)
+		if err == nil {
+			err = r.auditPending(ctx, pending)
+		}
+		// without a transaction what was written stays, even on error
+		r.notify(pending.changes...)
+		return err
+	}
+
+	session, err := r.mongo.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		// a retried transaction starts over
+		pending.changes = nil
+		pending.audits = nil
+		if err := fn(sc); err != nil {
+			return nil, err
+		}
+		return nil, r.auditPending(sc, pending)
+	})
+	if err != nil {
+		return err
+	}
+
+	r.notify(pending.changes...)
+	return nil
+}
+
+// record - stores event in the outbox, as part of the transaction in ctx.
+// Without an outbox nothing is recorded.
+func (r *MongoRepository) record(ctx context.Context, event Event) error {
+	if r.mongoOutbox == nil {
+		return nil
+	}
+
+	now := time.Now()
+	event.ID = uuid.NewV4().String()
+	event.Actor = actorFrom(ctx)
+	event.OccurredAt = now
+	entry := &OutboxEntry{
+		Event:       event,
+		NextAttempt: now,
+		LockedUntil: now,
+	}
+
+	_, err := r.mongoOutbox.InsertOne(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// pending - outbox entries neither published nor dead-lettered.
+func pending(filter bson.M) bson.M {
+	filter["published_at"] = bson.M{"$exists": false}
+	filter["dead_at"] = bson.M{"$exists": false}
+	return filter
+}
+
+// ClaimEvents - takes up to limit events that are due, oldest first, and
+// keeps them from other relays for lease. Events not marked published or
+// failed within the lease are claimed again.
+func (r *MongoRepository) ClaimEvents(ctx context.Context, limit int64, lease time.Duration) ([]*OutboxEntry, error) {
+	entries := []*OutboxEntry{}
+	if r.mongoOutbox == nil {
+		return entries, nil
+	}
+
+	for int64(len(entries)) < limit {
+		now := time.Now()
+		entry := OutboxEntry{}
+		err := r.mongoOutbox.FindOneAndUpdate(
+			ctx,
+			pending(bson.M{"next_attempt": bson.M{"$lte": now}, "locked_until": bson.M{"$lte": now}}),
+			bson.M{"$set": bson.M{"locked_until": now.Add(lease)}},
+			options.FindOneAndUpdate().SetSort(bson.M{"occurred_at": 1}).SetReturnDocument(options.After),
+		).Decode(&entry)
+		if err == mongo.ErrNoDocuments {
+			break
+		}
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, nil
+}
+
+// MarkPublished - records that an event reached the sink.
+func (r *MongoRepository) MarkPublished(ctx context.Context, id string) error {
+	_, err := r.mongoOutbox.UpdateOne(ctx, bson.M{"id": id}, bson.M{
+		"$set": bson.M{"published_at": time.Now()},
+	})
+	return err
+}
+
+// MarkFailed - records a failed attempt to publish an event, which is tried
+// again at next or, when dead, set aside until it is requeued.
+func (r *MongoRepository) MarkFailed(ctx context.Context, id string, attempts int, reason string, next time.Time, dead bool) error {
+	set := bson.M{
+		"attempts":     attempts,
+		"last_error":   reason,
+		"next_attempt": next,
+		"locked_until": time.Time{},
+	}
+	if dead {
+		set["dead_at"] = time.Now()
+	}
+
+	_, err := r.mongoOutbox.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": set})
+	return err
+}
+
+// PurgePublished - removes events published before the given time.
+func (r *MongoRepository) PurgePublished(ctx context.Context, before time.Time) (int64, error) {
+	if r.mongoOutbox == nil {
+		return 0, nil
+	}
+
+	result, err := r.mongoOutbox.DeleteMany(ctx, bson.M{"published_at": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
+
+// GetDeadEvents - returns the events that could not be published, oldest
+// first.
+func (r *MongoRepository) GetDeadEvents(ctx context.Context) ([]*OutboxEntry, error) {
+	entries := []*OutboxEntry{}
+	if r.mongoOutbox == nil {
+		return entries, nil
+	}
+
+	cursor, err := r.mongoOutbox.Find(
+		ctx,
+		bson.M{"dead_at": bson.M{"$exists": true}},
+		options.Find().SetSort(bson.M{"occurred_at": 1}),
+	)
+	if err != nil {
+		return entries, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var entry OutboxEntry
+		if err := cursor.Decode(&entry); err != nil {
+			return entries, err
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, cursor.Err()
+}
+
+// RequeueDead - gives the dead event with id, or every dead event when id is
+// empty, a fresh set of attempts.
+func (r *MongoRepository) RequeueDead(ctx context.Context, id string) (int64, error) {
+	if r.mongoOutbox == nil {
+		return 0, nil
+	}
+
+	filter := bson.M{"dead_at": bson.M{"$exists": true}}
+	if id != "" {
+		filter["id"] = id
+	}
+
+	result, err := r.mongoOutbox.UpdateMany(ctx, filter, bson.M{
+		"$set":   bson.M{"attempts": 0, "next_attempt": time.Now(), "locked_until": time.Time{}},
+		"$unset": bson.M{"dead_at": "", "last_error": ""},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
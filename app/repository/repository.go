@@ -2,7 +2,6 @@ package repository
 
 import (
 	"context"
-	"errors"
 	"strings"
 	"time"
 
@@ -16,41 +15,81 @@ import (
 
 // Privilege - struct.
 type Privilege struct {
-	ID                     string    `bson:"id" json:"id"`
-	Name                   string    `bson:"name" json:"name"`
-	ViewAllUsers           bool      `bson:"view_all_users" json:"view_all_users"`
-	CreateUser             bool      `bson:"create_user" json:"create_user"`
-	ManagePrivileges       bool      `bson:"manage_privileges" json:"manage_privileges"`
-	DeleteUser             bool      `bson:"delete_user" json:"delete_user"`
-	BlockUser              bool      `bson:"block_user" json:"block_user"`
-	SendResetPasswordEmail bool      `bson:"send_reset_password_email" json:"send_reset_password_email"`
-	CreatedAt              time.Time `bson:"created_at" json:"created_at"`
-	UpdatedAt              time.Time `bson:"updated_at" json:"updated_at"`
-	Default                bool      `bson:"default" json:"default"`
-	Root                   bool      `bson:"root" json:"root"`
+	ID                     string     `bson:"id" json:"id"`
+	Name                   string     `bson:"name" json:"name"`
+	ViewAllUsers           bool       `bson:"view_all_users" json:"view_all_users"`
+	CreateUser             bool       `bson:"create_user" json:"create_user"`
+	ManagePrivileges       bool       `bson:"manage_privileges" json:"manage_privileges"`
+	DeleteUser             bool       `bson:"delete_user" json:"delete_user"`
+	BlockUser              bool       `bson:"block_user" json:"block_user"`
+	SendResetPasswordEmail bool       `bson:"send_reset_password_email" json:"send_reset_password_email"`
+	CreatedAt              time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt              time.Time  `bson:"updated_at" json:"updated_at"`
+	Default                bool       `bson:"default" json:"default"`
+	Root                   bool       `bson:"root" json:"root"`
+	DeletedAt              *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	RemovedFromUsers       []string   `bson:"removed_from_users,omitempty" json:"removed_from_users,omitempty"`
+	RemovedFromGroups      []string   `bson:"removed_from_groups,omitempty" json:"removed_from_groups,omitempty"`
 }
 
 // Repository - interface.
 type Repository interface {
 	Create(ctx context.Context, priv *Privilege) error
 	CreateDefault(ctx context.Context) error
-	Update(ctx context.Context, priv *Privilege) error
+	Update(ctx context.Context, priv *Privilege, force bool) error
 	Get(ctx context.Context, priv *Privilege) (*Privilege, error)
 	GetDefault(ctx context.Context) (*Privilege, error)
 	GetRoot(ctx context.Context) (*Privilege, error)
 	GetAll(ctx context.Context) ([]*Privilege, error)
-	Delete(ctx context.Context, priv *Privilege) error
+	Delete(ctx context.Context, priv *Privilege, force bool) error
+	Clone(ctx context.Context, sourceID string, name string) (*Privilege, error)
+	Compare(ctx context.Context, aID string, bID string) (*Comparison, error)
+	GetDeleted(ctx context.Context) ([]*Privilege, error)
+	Restore(ctx context.Context, privilegeID string, restoreHolders bool) (*Privilege, error)
+	PurgeDeleted(ctx context.Context, olderThan time.Time) (int64, error)
+	Check(ctx context.Context, repair bool) (*CheckReport, error)
+	Export(ctx context.Context) (*Export, error)
+	Import(ctx context.Context, data *Export, force bool) (*ImportResult, error)
+	IssueBreakGlass(ctx context.Context, ttl time.Duration, maxUses int, reason string) (string, *BreakGlass, error)
+	UseBreakGlass(ctx context.Context, token string) (*BreakGlass, error)
+	RevokeBreakGlass(ctx context.Context) (int64, error)
+	AssignPrivilege(ctx context.Context, userID string, privilegeID string) error
+	RevokePrivilege(ctx context.Context, userID string, privilegeID string, force bool) error
+	GetEffectivePermissions(ctx context.Context, userID string) (*EffectivePermissions, error)
+	ListUsersWithPrivilege(ctx context.Context, privilegeID string, offset int64, limit int64) ([]*User, int64, error)
+	CountUsersPerPrivilege(ctx context.Context) ([]*PrivilegeCount, error)
+	MigrateUserPrivileges(ctx context.Context) (int64, error)
+	CreateGroup(ctx context.Context, group *Group) error
+	UpdateGroup(ctx context.Context, group *Group, force bool) error
+	GetGroup(ctx context.Context, groupID string) (*Group, error)
+	GetAllGroups(ctx context.Context) ([]*Group, error)
+	DeleteGroup(ctx context.Context, groupID string, force bool) error
+	AddGroupMember(ctx context.Context, groupID string, userID string) error
+	RemoveGroupMember(ctx context.Context, groupID string, userID string, force bool) error
 }
 
 // MongoRepository - struct.
 type MongoRepository struct {
-	mongo     *mongo.Collection
-	mongoUser *mongo.Collection
+	mongo           *mongo.Collection
+	mongoUser       *mongo.Collection
+	mongoGroup      *mongo.Collection
+	mongoAudit      *mongo.Collection
+	mongoBreakGlass *mongo.Collection
+	mongoOutbox     *mongo.Collection
+	mongoGuard      *mongo.Collection
+	minAdmins       int64
+	changes         *changeListeners
 }
 
-// NewRepository - returns MongoRepository pointer.
-func NewRepository(mongo *mongo.Collection, mongoUser *mongo.Collection) *MongoRepository {
-	return &MongoRepository{mongo, mongoUser}
+// NewRepository - returns MongoRepository pointer. minAdmins is the number of
+// non-root users that must keep the ability to manage privileges, at least one
+// whatever is given. Privilege changes are recorded as events in mongoOutbox,
+// unless it is nil. Changes that may take away the ability to manage privileges
+// write to a single document in mongoGuard, so concurrent ones conflict.
+// Unless both are nil changes run in transactions, which need mongo to run as
+// a replica set.
+func NewRepository(mongo *mongo.Collection, mongoUser *mongo.Collection, mongoGroup *mongo.Collection, mongoAudit *mongo.Collection, mongoBreakGlass *mongo.Collection, mongoOutbox *mongo.Collection, mongoGuard *mongo.Collection, minAdmins int64) *MongoRepository {
+	return &MongoRepository{mongo, mongoUser, mongoGroup, mongoAudit, mongoBreakGlass, mongoOutbox, mongoGuard, minAdmins, &changeListeners{}}
 }
 
 // MarshalPrivilegeCollection - unmarshal collection from proto.privilege to privileges
@@ -121,29 +160,29 @@ func (p *Privilege) validate(action string) error {
 		blockNoViewAccess := p.BlockUser && !p.ViewAllUsers
 		sendResetEmailNoViewAccess := p.SendResetPasswordEmail && !p.ViewAllUsers
 		if createNoViewAccess {
-			return errors.New("Create access not allowed without view access")
+			return invalid("create_user", "Create access not allowed without view access")
 		}
 		if deleteNoViewAccess {
-			return errors.New("Delete access not allowed without view access")
+			return invalid("delete_user", "Delete access not allowed without view access")
 		}
 		if managePrivilegesNoViewAccess {
-			return errors.New("Manage privileges access not allowed without view access")
+			return invalid("manage_privileges", "Manage privileges access not allowed without view access")
 		}
 		if blockNoViewAccess {
-			return errors.New("Block access not allowed without view access")
+			return invalid("block_user", "Block access not allowed without view access")
 		}
 		if sendResetEmailNoViewAccess {
-			return errors.New("Send reset email access not allowed without view access")
+			return invalid("send_reset_password_email", "Send reset email access not allowed without view access")
 		}
 		if p.Name == "" {
-			return errors.New("Name is required")
+			return invalid("name", "Name is required")
 		}
 		if p.ID == "" {
-			return errors.New("ID is required")
+			return invalid("id", "ID is required")
 		}
 	case "update":
 		if p.Default || p.Root {
-			return errors.New("Cannot update root privilege")
+			return protected("ROOT_PRIVILEGE", "Cannot update root privilege")
 		}
 		createNoViewAccess := p.CreateUser && !p.ViewAllUsers
 		deleteNoViewAccess := p.DeleteUser && !p.ViewAllUsers
@@ -151,29 +190,29 @@ func (p *Privilege) validate(action string) error {
 		blockNoViewAccess := p.BlockUser && !p.ViewAllUsers
 		sendResetEmailNoViewAccess := p.SendResetPasswordEmail && !p.ViewAllUsers
 		if createNoViewAccess {
-			return errors.New("Create access bot allowed without view access")
+			return invalid("create_user", "Create access bot allowed without view access")
 		}
 		if deleteNoViewAccess {
-			return errors.New("Delete access not allowed without view access")
+			return invalid("delete_user", "Delete access not allowed without view access")
 		}
 		if managePrivilegesNoViewAccess {
-			return errors.New("Manage privileges access not allowed without view access")
+			return invalid("manage_privileges", "Manage privileges access not allowed without view access")
 		}
 		if blockNoViewAccess {
-			return errors.New("Block access not allowed without view access")
+			return invalid("block_user", "Block access not allowed without view access")
 		}
 		if sendResetEmailNoViewAccess {
-			return errors.New("Send reset email access not allowed without view access")
+			return invalid("send_reset_password_email", "Send reset email access not allowed without view access")
 		}
 		if p.Name == "" {
-			return errors.New("Name is required")
+			return invalid("name", "Name is required")
 		}
 	case "delete":
 		if p.Default || p.Root {
-			return errors.New("Cannot delete root privilege")
+			return protected("ROOT_PRIVILEGE", "Cannot delete root privilege")
 		}
 	default:
-		return errors.New("Unknown action")
+		return invalid("action", "Unknown action")
 	}
 	return nil
 }
@@ -204,19 +243,20 @@ func (r *MongoRepository) Create(ctx context.Context, priv *Privilege) error {
 		return err
 	}
 
-	_, err := r.mongo.InsertOne(ctx, priv)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return r.transact(ctx, func(ctx context.Context) error {
+		if _, err := r.mongo.InsertOne(ctx, priv); err != nil {
+			return err
+		}
+		r.changed(ctx, ChangePrivilege, priv.ID)
+		return r.record(ctx, Event{Type: EventPrivilegeCreated, PrivilegeID: priv.ID, Privilege: priv})
+	})
 }
 
 // CreateDefault - creates a new default privilege.
 func (r *MongoRepository) CreateDefault(ctx context.Context) error {
 	_, err := r.GetDefault(ctx)
 	if err == nil {
-		return errors.New("Default privilege already exists")
+		return conflict("DEFAULT_PRIVILEGE_EXISTS", "Default privilege already exists")
 	}
 
 	priv := &Privilege{
@@ -229,6 +269,8 @@ func (r *MongoRepository) CreateDefault(ctx context.Context) error {
 		BlockUser:              false,
 		SendResetPasswordEmail: false,
 		Default:                true,
+		CreatedAt:              time.Now(),
+		UpdatedAt:              time.Now(),
 	}
 
 	_, err = r.mongo.InsertOne(ctx, priv)
@@ -243,7 +285,7 @@ func (r *MongoRepository) CreateDefault(ctx context.Context) error {
 func (r *MongoRepository) CreateRoot(ctx context.Context) error {
 	_, err := r.GetRoot(ctx)
 	if err == nil {
-		return errors.New("Root privilege already exists")
+		return conflict("ROOT_PRIVILEGE_EXISTS", "Root privilege already exists")
 	}
 
 	priv := &Privilege{
@@ -256,6 +298,8 @@ func (r *MongoRepository) CreateRoot(ctx context.Context) error {
 		BlockUser:              true,
 		SendResetPasswordEmail: true,
 		Root:                   true,
+		CreatedAt:              time.Now(),
+		UpdatedAt:              time.Now(),
 	}
 
 	_, err = r.mongo.InsertOne(ctx, priv)
@@ -266,14 +310,36 @@ func (r *MongoRepository) CreateRoot(ctx context.Context) error {
 	return nil
 }
 
-// Update - updates existing privilege by id
-func (r *MongoRepository) Update(ctx context.Context, priv *Privilege) error {
+// Update - updates existing privilege by id. If the update would leave too few
+// privilege administrators it is refused unless force is set.
+func (r *MongoRepository) Update(ctx context.Context, priv *Privilege, force bool) error {
 	priv.prepare("update")
 
 	if err := priv.validate("update"); err != nil {
 		return err
 	}
 
+	return r.transact(ctx, func(ctx context.Context) error {
+		return r.update(ctx, priv, force)
+	})
+}
+
+// update - the part of Update done in its transaction.
+func (r *MongoRepository) update(ctx context.Context, priv *Privilege, force bool) error {
+	current, err := r.Get(ctx, priv)
+	if err != nil {
+		return err
+	}
+	if err := current.validate("update"); err != nil {
+		return err
+	}
+
+	if current.ManagePrivileges && !priv.ManagePrivileges {
+		if err := r.guardLockout(ctx, "update", "privilege "+current.Name, grantRemoval{privilegeID: current.ID}, force); err != nil {
+			return err
+		}
+	}
+
 	updatePrivilege := bson.M{
 		"$set": bson.M{
 			"name":                      priv.Name,
@@ -287,7 +353,7 @@ func (r *MongoRepository) Update(ctx context.Context, priv *Privilege) error {
 		},
 	}
 
-	_, err := r.mongo.UpdateOne(
+	_, err = r.mongo.UpdateOne(
 		ctx,
 		bson.M{"id": priv.ID},
 		updatePrivilege,
@@ -297,15 +363,20 @@ func (r *MongoRepository) Update(ctx context.Context, priv *Privilege) error {
 		return err
 	}
 
-	return nil
+	updated, err := r.Get(ctx, priv)
+	if err != nil {
+		return err
+	}
+	r.changed(ctx, ChangePrivilege, updated.ID)
+	return r.record(ctx, Event{Type: EventPrivilegeUpdated, PrivilegeID: updated.ID, Privilege: updated})
 }
 
 // Get - finds single privilege using the privilege's id.
 func (r *MongoRepository) Get(ctx context.Context, priv *Privilege) (*Privilege, error) {
 	privReturn := Privilege{}
 
-	if err := r.mongo.FindOne(ctx, bson.M{"id": priv.ID}).Decode(&privReturn); err != nil {
-		return nil, err
+	if err := r.mongo.FindOne(ctx, notDeleted(bson.M{"id": priv.ID})).Decode(&privReturn); err != nil {
+		return nil, notFound("privilege", err)
 	}
 
 	return &privReturn, nil
@@ -315,7 +386,7 @@ func (r *MongoRepository) Get(ctx context.Context, priv *Privilege) (*Privilege,
 func (r *MongoRepository) GetDefault(ctx context.Context) (*Privilege, error) {
 	rootPriv := Privilege{}
 	if err := r.mongo.FindOne(ctx, bson.M{"default": true}).Decode(&rootPriv); err != nil {
-		return &Privilege{}, err
+		return &Privilege{}, notFound("default_privilege", err)
 	}
 	return &rootPriv, nil
 }
@@ -324,7 +395,7 @@ func (r *MongoRepository) GetDefault(ctx context.Context) (*Privilege, error) {
 func (r *MongoRepository) GetRoot(ctx context.Context) (*Privilege, error) {
 	rootPriv := Privilege{}
 	if err := r.mongo.FindOne(ctx, bson.M{"root": true}).Decode(&rootPriv); err != nil {
-		return &Privilege{}, err
+		return &Privilege{}, notFound("root_privilege", err)
 	}
 	return &rootPriv, nil
 }
@@ -333,7 +404,7 @@ func (r *MongoRepository) GetRoot(ctx context.Context) (*Privilege, error) {
 func (r *MongoRepository) GetAll(ctx context.Context) ([]*Privilege, error) {
 	privsReturn := []*Privilege{}
 
-	cursor, err := r.mongo.Find(context.TODO(), bson.M{})
+	cursor, err := r.mongo.Find(ctx, notDeleted(bson.M{}))
 
 	if err != nil {
 		return []*Privilege{}, err
@@ -349,38 +420,61 @@ func (r *MongoRepository) GetAll(ctx context.Context) ([]*Privilege, error) {
 	return privsReturn, nil
 }
 
-// Delete - deletes a given privilege by id.
-func (r *MongoRepository) Delete(ctx context.Context, priv *Privilege) error {
-	if err := priv.validate("delete"); err != nil {
-		return err
-	}
+// Delete - moves a given privilege by id to the trash, where it can be restored
+// from until it is purged. Users and groups holding it lose it and are
+// remembered on the deleted privilege. If the delete would leave too few
+// privilege administrators it is refused unless force is set.
+func (r *MongoRepository) Delete(ctx context.Context, priv *Privilege, force bool) error {
+	return r.transact(ctx, func(ctx context.Context) error {
+		return r.delete(ctx, priv, force)
+	})
+}
 
-	// set all users with that privilege to default privilege
-	defaultPrivilege, err := r.GetDefault(ctx)
+// delete - the part of Delete done in its transaction.
+func (r *MongoRepository) delete(ctx context.Context, priv *Privilege, force bool) error {
+	current, err := r.Get(ctx, priv)
 	if err != nil {
 		return err
 	}
-	updateUsers := bson.M{
-		"$set": bson.M{
-			"privilege_id": defaultPrivilege.ID,
-			"updated_at":   time.Now(),
-		},
+	if err := current.validate("delete"); err != nil {
+		return err
+	}
+
+	if current.ManagePrivileges {
+		if err := r.guardLockout(ctx, "delete", "privilege "+current.Name, grantRemoval{privilegeID: current.ID}, force); err != nil {
+			return err
+		}
 	}
-	_, err = r.mongoUser.UpdateMany(
-		ctx,
-		bson.M{"privilege_id": priv.ID},
-		updateUsers,
-	)
 
+	users, groups, err := r.holders(ctx, current.ID)
 	if err != nil {
 		return err
 	}
 
+	if err := r.removeFromUsers(ctx, current.ID); err != nil {
+		return err
+	}
+	if err := r.removeFromGroups(ctx, current.ID); err != nil {
+		return err
+	}
+
 	// now delete
-	_, err = r.mongo.DeleteOne(ctx, bson.M{"id": priv.ID})
+	deletedAt := time.Now()
+	deletePrivilege := bson.M{
+		"$set": bson.M{
+			"deleted_at":          deletedAt,
+			"removed_from_users":  users,
+			"removed_from_groups": groups,
+		},
+	}
+	_, err = r.mongo.UpdateOne(ctx, bson.M{"id": current.ID}, deletePrivilege)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	current.DeletedAt = &deletedAt
+	current.RemovedFromUsers = users
+	current.RemovedFromGroups = groups
+	r.changed(ctx, ChangePrivilege, current.ID)
+	return r.record(ctx, Event{Type: EventPrivilegeDeleted, PrivilegeID: current.ID, Privilege: current})
 }
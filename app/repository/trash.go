@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// notDeleted - filter matching privileges that are not in the trash.
+func notDeleted(filter bson.M) bson.M {
+	filter["deleted_at"] = nil
+	return filter
+}
+
+// holders - returns the ids of the users and groups holding a privilege.
+func (r *MongoRepository) holders(ctx context.Context, privilegeID string) ([]string, []string, error) {
+	cursor, err := r.mongoUser.Find(ctx, holding(privilegeID), options.Find().SetProjection(bson.M{"id": 1}))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []string{}
+	for cursor.Next(ctx) {
+		var tempUser User
+		if err := cursor.Decode(&tempUser); err != nil {
+			return nil, nil, err
+		}
+		users = append(users, tempUser.ID)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	groups, err := r.groupsWithPrivileges(ctx, []string{privilegeID})
+	if err != nil {
+		return nil, nil, err
+	}
+	groupIDs := []string{}
+	for _, group := range groups {
+		groupIDs = append(groupIDs, group.ID)
+	}
+
+	return users, groupIDs, nil
+}
+
+// GetDeleted - returns every privilege in the trash.
+func (r *MongoRepository) GetDeleted(ctx context.Context) ([]*Privilege, error) {
+	privsReturn := []*Privilege{}
+
+	cursor, err := r.mongo.Find(ctx, bson.M{"deleted_at": bson.M{"$ne": nil}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var tempPriv Privilege
+		if err := cursor.Decode(&tempPriv); err != nil {
+			return nil, err
+		}
+		privsReturn = append(privsReturn, &tempPriv)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return privsReturn, nil
+}
+
+// Restore - brings a privilege back from the trash. With restoreHolders the
+// users and groups that lost it on delete get it back.
+func (r *MongoRepository) Restore(ctx context.Context, privilegeID string, restoreHolders bool) (*Privilege, error) {
+	if privilegeID == "" {
+		return nil, invalid("id", "ID is required")
+	}
+
+	var restored *Privilege
+	err := r.transact(ctx, func(ctx context.Context) error {
+		var err error
+		restored, err = r.restore(ctx, privilegeID, restoreHolders)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return restored, nil
+}
+
+// restore - the part of Restore done in its transaction.
+func (r *MongoRepository) restore(ctx context.Context, privilegeID string, restoreHolders bool) (*Privilege, error) {
+	priv := Privilege{}
+	filter := bson.M{"id": privilegeID, "deleted_at": bson.M{"$ne": nil}}
+	if err := r.mongo.FindOne(ctx, filter).Decode(&priv); err != nil {
+		return nil, notFound("deleted_privilege", err)
+	}
+
+	restorePrivilege := bson.M{
+		"$unset": bson.M{
+			"deleted_at":          "",
+			"removed_from_users":  "",
+			"removed_from_groups": "",
+		},
+		"$set": bson.M{
+			"updated_at": time.Now(),
+		},
+	}
+	if _, err := r.mongo.UpdateOne(ctx, filter, restorePrivilege); err != nil {
+		return nil, err
+	}
+
+	restored, err := r.Get(ctx, &priv)
+	if err != nil {
+		return nil, err
+	}
+	r.changed(ctx, ChangePrivilege, restored.ID)
+	if err := r.record(ctx, Event{Type: EventPrivilegeRestored, PrivilegeID: restored.ID, Privilege: restored}); err != nil {
+		return nil, err
+	}
+
+	if !restoreHolders {
+		return restored, nil
+	}
+
+	for _, userID := range priv.RemovedFromUsers {
+		// users deleted in the meantime are skipped
+		err := r.assign(ctx, userID, priv.ID, false)
+		if err != nil && !IsNotFound(err) {
+			return nil, err
+		}
+	}
+	for _, groupID := range priv.RemovedFromGroups {
+		group, err := r.GetGroup(ctx, groupID)
+		if IsNotFound(err) {
+			// groups deleted in the meantime are skipped
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = r.mongoGroup.UpdateOne(
+			ctx,
+			bson.M{"id": group.ID},
+			bson.M{
+				"$addToSet": bson.M{"privilege_ids": priv.ID},
+				"$set":      bson.M{"updated_at": time.Now()},
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		updated, err := r.GetGroup(ctx, group.ID)
+		if err != nil {
+			return nil, err
+		}
+		r.changed(ctx, ChangeGroup, updated.ID)
+		if err := r.record(ctx, Event{Type: EventGroupUpdated, GroupID: updated.ID, Group: updated}); err != nil {
+			return nil, err
+		}
+	}
+
+	return restored, nil
+}
+
+// PurgeDeleted - removes privileges that were moved to the trash before
+// olderThan for good and records an event for each.
+func (r *MongoRepository) PurgeDeleted(ctx context.Context, olderThan time.Time) (int64, error) {
+	var purged int64
+	err := r.transact(ctx, func(ctx context.Context) error {
+		purged = 0
+		cursor, err := r.mongo.Find(ctx, bson.M{"deleted_at": bson.M{"$lt": olderThan}})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		privileges := []*Privilege{}
+		for cursor.Next(ctx) {
+			var tempPriv Privilege
+			if err := cursor.Decode(&tempPriv); err != nil {
+				return err
+			}
+			privileges = append(privileges, &tempPriv)
+		}
+		if err := cursor.Err(); err != nil {
+			return err
+		}
+
+		for _, priv := range privileges {
+			result, err := r.mongo.DeleteOne(ctx, bson.M{"id": priv.ID, "deleted_at": bson.M{"$lt": olderThan}})
+			if err != nil {
+				return err
+			}
+			if result.DeletedCount == 0 {
+				// restored or purged in the meantime
+				continue
+			}
+			purged++
+			if err := r.record(ctx, Event{Type: EventPrivilegePurged, PrivilegeID: priv.ID, Privilege: priv}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,388 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxPageSize - upper bound for paginated user listings.
+const maxPageSize = 100
+
+// User - the parts of a user document this service reads. PrivilegeIDs holds
+// every privilege of the user, PrivilegeID mirrors its first entry for services
+// that only know about a single privilege. Nothing here authorizes by
+// PrivilegeID alone, see GetEffectivePermissions.
+type User struct {
+	ID           string   `bson:"id" json:"id"`
+	Name         string   `bson:"name" json:"name"`
+	Email        string   `bson:"email" json:"email"`
+	PrivilegeID  string   `bson:"privilege_id" json:"privilege_id"`
+	PrivilegeIDs []string `bson:"privilege_ids,omitempty" json:"privilege_ids"`
+	Blocked      bool     `bson:"blocked" json:"blocked"`
+}
+
+// PrivilegeCount - number of users holding a privilege.
+type PrivilegeCount struct {
+	PrivilegeID string `json:"privilege_id"`
+	Name        string `json:"name"`
+	Users       int64  `json:"users"`
+}
+
+// privileges - returns the privilege ids of the user, falling back to the
+// single field layout for users that have not been migrated.
+func (u *User) privileges() []string {
+	if len(u.PrivilegeIDs) > 0 {
+		return u.PrivilegeIDs
+	}
+	if u.PrivilegeID != "" {
+		return []string{u.PrivilegeID}
+	}
+	return []string{}
+}
+
+// holding - filter matching users that hold any of the given privileges, in
+// either the multi or the single field layout.
+func holding(ids ...string) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"privilege_ids": bson.M{"$in": ids}},
+		{"privilege_ids": bson.M{"$exists": false}, "privilege_id": bson.M{"$in": ids}},
+	}}
+}
+
+// getUser - finds a single user by id.
+func (r *MongoRepository) getUser(ctx context.Context, userID string) (*User, error) {
+	if userID == "" {
+		return nil, invalid("user_id", "User ID is required")
+	}
+
+	user := User{}
+	if err := r.mongoUser.FindOne(ctx, bson.M{"id": userID}).Decode(&user); err != nil {
+		return nil, notFound("user", err)
+	}
+
+	return &user, nil
+}
+
+// setUserPrivileges - stores the privilege set of a user and mirrors the first
+// entry into privilege_id.
+func (r *MongoRepository) setUserPrivileges(ctx context.Context, userID string, ids []string) error {
+	updateUser := bson.M{
+		"$set": bson.M{
+			"privilege_ids": ids,
+			"privilege_id":  ids[0],
+			"updated_at":    time.Now(),
+		},
+	}
+	_, err := r.mongoUser.UpdateOne(
+		ctx,
+		bson.M{"id": userID},
+		updateUser,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	r.changed(ctx, ChangeUser, userID)
+	return nil
+}
+
+// AssignPrivilege - adds the privilege with privilegeID to the privileges of a
+// user. The default privilege is dropped once the user holds another one. The
+// root privilege can not be assigned, see AssignRoot.
+func (r *MongoRepository) AssignPrivilege(ctx context.Context, userID string, privilegeID string) error {
+	return r.transact(ctx, func(ctx context.Context) error {
+		return r.assign(ctx, userID, privilegeID, false)
+	})
+}
+
+// AssignRoot - adds the root privilege to the privileges of a user. It is
+// meant for operators bootstrapping a system and is not exposed over grpc.
+func (r *MongoRepository) AssignRoot(ctx context.Context, userID string) error {
+	root, err := r.GetRoot(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.transact(ctx, func(ctx context.Context) error {
+		return r.assign(ctx, userID, root.ID, true)
+	})
+}
+
+// assign - the part of AssignPrivilege done in its transaction. The root
+// privilege is only assigned with allowRoot.
+func (r *MongoRepository) assign(ctx context.Context, userID string, privilegeID string, allowRoot bool) error {
+	user, err := r.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if privilegeID == "" {
+		return invalid("privilege_id", "Privilege ID is required")
+	}
+	priv, err := r.Get(ctx, &Privilege{ID: privilegeID})
+	if err != nil {
+		return err
+	}
+	if priv.Root && !allowRoot {
+		return protected("ROOT_PRIVILEGE", "Cannot assign root privilege")
+	}
+
+	ids := []string{}
+	for _, id := range user.privileges() {
+		if id == priv.ID {
+			return nil
+		}
+		if held, err := r.Get(ctx, &Privilege{ID: id}); err == nil && held.Default {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	ids = append(ids, priv.ID)
+
+	if err := r.setUserPrivileges(ctx, user.ID, ids); err != nil {
+		return err
+	}
+	err = r.audit(ctx, &AuditEntry{
+		Action:      "assign",
+		PrivilegeID: priv.ID,
+		UserID:      user.ID,
+		Message:     fmt.Sprintf("Privilege %s assigned to user %s", priv.Name, user.ID),
+	})
+	if err != nil {
+		return err
+	}
+	return r.record(ctx, Event{Type: EventPrivilegeAssigned, PrivilegeID: priv.ID, UserID: user.ID, PrivilegeIDs: ids})
+}
+
+// RevokePrivilege - removes the privilege with privilegeID from a user, who
+// falls back to the default privilege when nothing is left. If the user is the
+// last administrator allowed to manage privileges it is refused unless force
+// is set.
+func (r *MongoRepository) RevokePrivilege(ctx context.Context, userID string, privilegeID string, force bool) error {
+	return r.transact(ctx, func(ctx context.Context) error {
+		return r.revoke(ctx, userID, privilegeID, force)
+	})
+}
+
+// revoke - the part of RevokePrivilege done in its transaction.
+func (r *MongoRepository) revoke(ctx context.Context, userID string, privilegeID string, force bool) error {
+	user, err := r.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if privilegeID == "" {
+		return invalid("privilege_id", "Privilege ID is required")
+	}
+
+	ids := []string{}
+	found := false
+	for _, id := range user.privileges() {
+		if id == privilegeID {
+			found = true
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if !found {
+		return conflict("PRIVILEGE_NOT_HELD", "User does not hold privilege")
+	}
+
+	current, err := r.Get(ctx, &Privilege{ID: privilegeID})
+	if err == nil && current.ManagePrivileges {
+		if err := r.guardLockout(ctx, "revoke", "privilege "+current.Name+" from user "+user.ID, grantRemoval{privilegeID: current.ID, userID: user.ID}, force); err != nil {
+			return err
+		}
+	}
+
+	if len(ids) == 0 {
+		defaultPrivilege, err := r.GetDefault(ctx)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, defaultPrivilege.ID)
+	}
+
+	if err := r.setUserPrivileges(ctx, user.ID, ids); err != nil {
+		return err
+	}
+	err = r.audit(ctx, &AuditEntry{
+		Action:      "revoke",
+		PrivilegeID: privilegeID,
+		UserID:      user.ID,
+		Message:     fmt.Sprintf("Privilege %s revoked from user %s", privilegeID, user.ID),
+	})
+	if err != nil {
+		return err
+	}
+	return r.record(ctx, Event{Type: EventPrivilegeRevoked, PrivilegeID: privilegeID, UserID: user.ID, PrivilegeIDs: ids})
+}
+
+// removeFromUsers - takes the privilege with privilegeID away from every user
+// holding it. Users left without privileges fall back to the default privilege.
+func (r *MongoRepository) removeFromUsers(ctx context.Context, privilegeID string) error {
+	if _, err := r.MigrateUserPrivileges(ctx); err != nil {
+		return err
+	}
+
+	defaultPrivilege, err := r.GetDefault(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.mongoUser.UpdateMany(
+		ctx,
+		bson.M{"privilege_ids": privilegeID},
+		bson.M{
+			"$pull": bson.M{"privilege_ids": privilegeID},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.mongoUser.UpdateMany(
+		ctx,
+		bson.M{"privilege_ids": bson.M{"$size": 0}},
+		bson.M{"$set": bson.M{"privilege_ids": []string{defaultPrivilege.ID}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	// keep the single field mirror pointing at an existing privilege
+	_, err = r.mongoUser.UpdateMany(
+		ctx,
+		bson.M{"privilege_id": privilegeID},
+		[]bson.M{
+			{"$set": bson.M{"privilege_id": bson.M{"$arrayElemAt": []interface{}{"$privilege_ids", 0}}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ListUsersWithPrivilege - returns a page of users holding the privilege with
+// privilegeID together with the total number of holders.
+func (r *MongoRepository) ListUsersWithPrivilege(ctx context.Context, privilegeID string, offset int64, limit int64) ([]*User, int64, error) {
+	if privilegeID == "" {
+		return nil, 0, invalid("privilege_id", "Privilege ID is required")
+	}
+	if _, err := r.Get(ctx, &Privilege{ID: privilegeID}); err != nil {
+		return nil, 0, err
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 || limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	filter := holding(privilegeID)
+
+	total, err := r.mongoUser.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.M{"id": 1}).
+		SetSkip(offset).
+		SetLimit(limit).
+		SetProjection(bson.M{"id": 1, "name": 1, "email": 1, "privilege_id": 1, "privilege_ids": 1, "blocked": 1})
+
+	cursor, err := r.mongoUser.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []*User{}
+	for cursor.Next(ctx) {
+		var tempUser User
+		if err := cursor.Decode(&tempUser); err != nil {
+			return nil, 0, err
+		}
+		tempUser.PrivilegeIDs = tempUser.privileges()
+		users = append(users, &tempUser)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// CountUsersPerPrivilege - returns the number of users holding each privilege.
+func (r *MongoRepository) CountUsersPerPrivilege(ctx context.Context) ([]*PrivilegeCount, error) {
+	privileges, err := r.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.mongoUser.Aggregate(ctx, []bson.M{
+		{"$project": bson.M{"ids": bson.M{"$ifNull": []interface{}{"$privilege_ids", []string{"$privilege_id"}}}}},
+		{"$unwind": "$ids"},
+		{"$group": bson.M{"_id": "$ids", "users": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	counts := map[string]int64{}
+	for cursor.Next(ctx) {
+		var group struct {
+			ID    string `bson:"_id"`
+			Users int64  `bson:"users"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return nil, err
+		}
+		counts[group.ID] = group.Users
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	result := []*PrivilegeCount{}
+	for _, priv := range privileges {
+		result = append(result, &PrivilegeCount{
+			PrivilegeID: priv.ID,
+			Name:        priv.Name,
+			Users:       counts[priv.ID],
+		})
+	}
+
+	return result, nil
+}
+
+// MigrateUserPrivileges - copies privilege_id into privilege_ids for users
+// stored in the single privilege layout. It is safe to run repeatedly.
+func (r *MongoRepository) MigrateUserPrivileges(ctx context.Context) (int64, error) {
+	result, err := r.mongoUser.UpdateMany(
+		ctx,
+		bson.M{
+			"privilege_ids": bson.M{"$exists": false},
+			"privilege_id":  bson.M{"$exists": true, "$ne": ""},
+		},
+		[]bson.M{
+			{"$set": bson.M{"privilege_ids": []string{"$privilege_id"}}},
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
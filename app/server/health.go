@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+	health "github.com/softcorp-io/hqs-privileges-service/health"
+	userservice "github.com/softcorp-io/hqs-privileges-service/userservice"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// serveHealth - serves only the health service of checker on port, without
+// tls, until the returned server is stopped.
+func serveHealth(zapLog *zap.Logger, checker *health.Checker, port string) (*grpc.Server, error) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
+	if err != nil {
+		return nil, err
+	}
+
+	healthServer := grpc.NewServer()
+	healthpb.RegisterHealthServer(healthServer, checker.Server())
+	go func() {
+		zapLog.Info(fmt.Sprintf("Health service running on port: %s", port))
+		if err := healthServer.Serve(lis); err != nil {
+			zapLog.Error(fmt.Sprintf("Health service failed with err %v", err))
+		}
+	}()
+
+	return healthServer, nil
+}
+
+// addHealthChecks - checks Mongo and, if configured, that the user service
+// answers.
+func addHealthChecks(checker *health.Checker, client *mongo.Client, users *userservice.Client, cfg *config.Config) {
+	checker.Add("mongo", func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	})
+
+	if !cfg.Health.CheckUserService {
+		return
+	}
+	checker.Add("user_service", users.Ping)
+}
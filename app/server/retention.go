@@ -0,0 +1,35 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"go.uber.org/zap"
+)
+
+// retentionInterval - how often the trash is checked for expired privileges.
+const retentionInterval = time.Hour
+
+// runRetention - purges privileges that have been in the trash for longer
+// than retention, until ctx is done.
+func runRetention(ctx context.Context, zapLog *zap.Logger, repo repository.Repository, retention time.Duration) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := repo.PurgeDeleted(ctx, time.Now().Add(-retention))
+		if err != nil {
+			zapLog.Error(fmt.Sprintf("Could not purge deleted privileges with err %v", err))
+		} else if purged > 0 {
+			zapLog.Info(fmt.Sprintf("Purged %d deleted privileges", purged))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -2,113 +2,309 @@ package server
 
 import (
 	"context"
-	"errors"
+	"crypto/tls"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/joho/godotenv"
+	auth "github.com/softcorp-io/hqs-privileges-service/auth"
+	certs "github.com/softcorp-io/hqs-privileges-service/certs"
+	config "github.com/softcorp-io/hqs-privileges-service/config"
 	database "github.com/softcorp-io/hqs-privileges-service/database"
+	gateway "github.com/softcorp-io/hqs-privileges-service/gateway"
 	handler "github.com/softcorp-io/hqs-privileges-service/handler"
+	health "github.com/softcorp-io/hqs-privileges-service/health"
+	logging "github.com/softcorp-io/hqs-privileges-service/logging"
+	metrics "github.com/softcorp-io/hqs-privileges-service/metrics"
+	outbox "github.com/softcorp-io/hqs-privileges-service/outbox"
+	ratelimit "github.com/softcorp-io/hqs-privileges-service/ratelimit"
 	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	tracing "github.com/softcorp-io/hqs-privileges-service/tracing"
+	userservice "github.com/softcorp-io/hqs-privileges-service/userservice"
 	privilegeProto "github.com/softcorp-io/hqs_proto/go_hqs/hqs_privilege_service"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
-type collectionEnv struct {
-	privilegeCollection string
-	userCollection      string
-}
+// Connect - connects to mongo and creates the repository on top of it. The
+// returned client must be disconnected by the caller. Commands are reported
+// to monitor, if given. Privilege changes are recorded in the outbox only when
+// events are published. Mongo must support transactions, see
+// repository.NewRepository.
+func Connect(zapLog *zap.Logger, cfg *config.Config, monitor *event.CommandMonitor) (*mongo.Client, *repository.MongoRepository, error) {
+	client, err := database.Connect(zapLog, cfg.Mongo, monitor)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Could not make connection to DB with err %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Mongo.ConnectTimeout))
+	defer cancel()
+	if err := database.RequireTransactions(ctx, client); err != nil {
+		client.Disconnect(ctx)
+		return nil, nil, fmt.Errorf("Could not use DB with err %v", err)
+	}
 
-// Init - initialize .env variables.
-func Init(zapLog *zap.Logger) {
-	if err := godotenv.Load("hqs.env"); err != nil {
-		zapLog.Error(fmt.Sprintf("Could not load hqs.env with err %v", err))
+	mongodb := client.Database(cfg.Mongo.DBName)
+	collections := cfg.Mongo.Collections
+
+	privilegeCollection := mongodb.Collection(collections.Privilege)
+	usersCollection := mongodb.Collection(collections.User)
+	groupCollection := mongodb.Collection(collections.Group)
+	auditCollection := mongodb.Collection(collections.Audit)
+	breakGlassCollection := mongodb.Collection(collections.BreakGlass)
+	guardCollection := mongodb.Collection(collections.Guard)
+	var outboxCollection *mongo.Collection
+	if cfg.Outbox.Sink != config.OutboxNone {
+		outboxCollection = mongodb.Collection(collections.Outbox)
 	}
+
+	// setup repository
+	repo := repository.NewRepository(privilegeCollection, usersCollection, groupCollection, auditCollection, breakGlassCollection, outboxCollection, guardCollection, cfg.Privileges.MinAdmins)
+
+	return client, repo, nil
 }
 
-func loadCollections() (collectionEnv, error) {
-	privilegeCollection, ok := os.LookupEnv("MONGO_DB_PRIVILEGE_COLLECTION")
-	if !ok {
-		return collectionEnv{}, errors.New("Required MONGO_DB_PRIVILEGE_COLLECTION")
+// Bootstrap - creates the default and root privileges when missing and
+// migrates users to the multiple privileges layout.
+func Bootstrap(ctx context.Context, zapLog *zap.Logger, repo *repository.MongoRepository) {
+	if err := repo.CreateDefault(ctx); err != nil {
+		zapLog.Info(fmt.Sprintf("%v", err))
+	} else {
+		zapLog.Info("Created default privilege!")
+	}
+	if err := repo.CreateRoot(ctx); err != nil {
+		zapLog.Info(fmt.Sprintf("%v", err))
+	} else {
+		zapLog.Info("Created root privilege!")
 	}
-	userCollection, ok := os.LookupEnv("MONGO_DB_USER_COLLECTION")
-	if !ok {
-		return collectionEnv{}, errors.New("Required MONGO_DB_USER_COLLECTION")
+	if migrated, err := repo.MigrateUserPrivileges(ctx); err != nil {
+		zapLog.Error(fmt.Sprintf("Could not migrate user privileges with err %v", err))
+	} else if migrated > 0 {
+		zapLog.Info(fmt.Sprintf("Migrated %d users to multiple privileges", migrated))
 	}
-	return collectionEnv{privilegeCollection, userCollection}, nil
 }
 
-// Run - runs a go microservice. Uses zap for logging and a waitGroup for async testing.
-func Run(zapLog *zap.Logger, wg *sync.WaitGroup) {
-	// creates a database connection and closes it when done
-	mongoenv, err := database.GetMongoEnv()
+// Run - runs a go microservice. Uses zap for logging at level, which the
+// admin service can change, and a waitGroup for async testing, which is
+// signaled once the service has shut down.
+func Run(zapLog *zap.Logger, level zap.AtomicLevel, cfg *config.Config, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	m := metrics.New()
+
+	stopTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
 	if err != nil {
-		zapLog.Fatal(fmt.Sprintf("Could not set up mongo env with err %v", err))
+		zapLog.Fatal(fmt.Sprintf("Could not set up tracing with err %v", err))
 	}
-	// build uri for mongodb
-	mongouri := fmt.Sprintf("mongodb+srv://%s:%s@%s/%s?retryWrites=true&w=majority", mongoenv.User, mongoenv.Password, mongoenv.Host, mongoenv.DBname)
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	mongo, err := database.NewMongoDatabase(ctx, zapLog, mongouri)
+	// creates a database connection and closes it when done
+	client, repo, err := Connect(zapLog, cfg, database.Monitors(m.CommandMonitor(), tracing.CommandMonitor()))
 	if err != nil {
-		zapLog.Fatal(fmt.Sprintf("Could not make connection to DB with err %v", err))
+		zapLog.Fatal(err.Error())
 	}
 
-	defer mongo.Disconnect(context.Background())
+	Bootstrap(context.Background(), zapLog, repo)
 
-	mongodb := mongo.Database(mongoenv.DBname)
+	// everything but bootstrapping goes through the instrumented repository
+	store := repository.Instrument(repo, tracing.RepositoryHook, m.RepositoryHook)
 
-	collections, err := loadCollections()
+	// background workers run until ctx is cancelled on shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		runRetention(ctx, zapLog, store, time.Duration(cfg.Privileges.TrashRetention))
+	}()
+
+	sink, err := outbox.NewSink(cfg.Outbox)
 	if err != nil {
-		zapLog.Fatal(fmt.Sprintf("Could not load collections with err: %v", err))
+		zapLog.Fatal(fmt.Sprintf("Could not set up outbox sink with err %v", err))
+	}
+	if sink != nil {
+		relay := outbox.NewRelay(repo, sink, outbox.Options{
+			BatchSize:    cfg.Outbox.BatchSize,
+			MaxAttempts:  int(cfg.Outbox.MaxAttempts),
+			RetryBackoff: time.Duration(cfg.Outbox.RetryBackoff),
+			Retention:    time.Duration(cfg.Outbox.Retention),
+		}, zapLog)
+		repo.OnChange(func(change repository.Change) {
+			if change.Kind == repository.ChangePrivilege {
+				relay.Notify()
+			}
+		})
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			relay.Run(ctx, time.Duration(cfg.Outbox.PollInterval))
+		}()
 	}
 
-	privilegeCollection := mongodb.Collection(collections.privilegeCollection)
-	usersCollection := mongodb.Collection(collections.userCollection)
+	serverCerts, userServiceCerts, err := loadCertificates(ctx, zapLog, cfg, &workers)
+	if err != nil {
+		zapLog.Fatal(fmt.Sprintf("Could not load certificates with err %v", err))
+	}
 
-	// setup repository
-	repo := repository.NewRepository(privilegeCollection, usersCollection)
+	users, err := userservice.NewClient(cfg.UserService, userServiceCerts)
+	if err != nil {
+		zapLog.Fatal(fmt.Sprintf("Could not create user service client with err %v", err))
+	}
 
-	if err := repo.CreateDefault(context.Background()); err != nil {
-		zapLog.Info(fmt.Sprintf("%v", err))
-	} else {
-		zapLog.Info("Created default privilege!")
+	// the configured modes tell who a caller is and the stored privileges,
+	// direct and through groups, what the caller may do; both are cached until
+	// a privilege, user or group changes. Break glass credentials are checked
+	// before the cache as every use counts
+	authenticator, err := auth.FromConfig(cfg.Auth, m.InstrumentValidator(users))
+	if err != nil {
+		zapLog.Fatal(fmt.Sprintf("Could not set up authentication with err %v", err))
 	}
-	if err := repo.CreateRoot(context.Background()); err != nil {
-		zapLog.Info(fmt.Sprintf("%v", err))
-	} else {
-		zapLog.Info("Created root privilege!")
+	if cfg.Auth.Uses(config.AuthStatic) {
+		zapLog.Warn("Static dev authentication is enabled, do not use it in production")
 	}
+	cache := auth.NewCache(auth.NewPermissions(authenticator, store), cfg.TokenCache)
+	repo.OnChange(func(repository.Change) {
+		cache.Invalidate()
+	})
+	authenticator = auth.NewBreakGlass(cache, store)
+	m.ExportUserService(users)
+	m.ExportTokenCache(cache)
+
+	checker := health.NewChecker(zapLog, handler.PrivilegeServiceName, handler.AdminServiceName)
+	addHealthChecks(checker, client, users, cfg)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		checker.Run(ctx, time.Duration(cfg.Health.Interval))
+	}()
 
 	// use above to create handler
-	handle := handler.NewHandler(repo, zapLog)
+	handle := handler.NewHandler(store, zapLog, level)
+	calls := logging.NewInterceptor(zapLog)
+	guard := auth.NewGuard(authenticator, handler.Policy(), zapLog)
 
-	// create the service and run the service
-	port, ok := os.LookupEnv("SERVICE_PORT")
-	if !ok {
-		zapLog.Fatal("Could not get service port")
+	tlsUnary, tlsStream, serverOptions, err := tlsOptions(cfg, serverCerts)
+	if err != nil {
+		zapLog.Fatal(fmt.Sprintf("Could not set up tls with err %v", err))
+	}
+
+	limiter, err := newLimiter(cfg)
+	if err != nil {
+		zapLog.Fatal(fmt.Sprintf("Could not set up limits with err %v", err))
 	}
 
+	// calls are traced as a whole, logs and metrics see every call as the
+	// caller does, errors are turned into status errors right inside so every
+	// error leaving the service carries a proper code, client certificates are
+	// checked before tokens and limits apply to callers once they are known
+	unaryInterceptors := append([]grpc.UnaryServerInterceptor{tracing.UnaryServerInterceptor, calls.UnaryInterceptor, m.UnaryInterceptor, handler.ErrorInterceptor}, tlsUnary...)
+	unaryInterceptors = append(unaryInterceptors, guard.UnaryInterceptor, limiter.UnaryInterceptor)
+	streamInterceptors := append([]grpc.StreamServerInterceptor{tracing.StreamServerInterceptor, calls.StreamInterceptor, m.StreamInterceptor, handler.StreamErrorInterceptor}, tlsStream...)
+	streamInterceptors = append(streamInterceptors, guard.StreamInterceptor, limiter.StreamInterceptor)
+
+	// create the service and run the service
+	port := cfg.Service.Port
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
 	if err != nil {
 		zapLog.Fatal(fmt.Sprintf("Failed to listen with err %v", err))
 	}
-	defer lis.Close()
 
 	zapLog.Info(fmt.Sprintf("Service running on port: %s", port))
 
+	// serve the same handler as json over http if a port is given
+	var httpServer *http.Server
+	if cfg.Service.HTTPPort != "" {
+		var gatewayTLS *tls.Config
+		if serverCerts != nil {
+			gatewayTLS = certs.ServerConfig(serverCerts)
+		}
+		httpServer = gateway.NewGateway(handle, zapLog, unaryInterceptors...).ListenAndServe(cfg.Service.HTTPPort, gatewayTLS, cfg.Service.HTTPTimeouts)
+	}
+
+	// serve metrics on their own port, away from the public load balancer
+	var metricsServer *http.Server
+	if cfg.Metrics.Port != "" {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			m.WatchPrivileges(ctx, zapLog, store, time.Duration(cfg.Metrics.CountInterval))
+		}()
+		metricsServer = m.ListenAndServe(zapLog, cfg.Metrics.Port)
+	}
+
+	// serve health without tls for probes that can not present a certificate
+	var healthServer *grpc.Server
+	if cfg.Service.HealthPort != "" {
+		healthServer, err = serveHealth(zapLog, checker, cfg.Service.HealthPort)
+		if err != nil {
+			zapLog.Fatal(fmt.Sprintf("Failed to listen with err %v", err))
+		}
+	}
+
 	// setup grpc
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(append(serverOptions,
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)...)
 
 	// register handler
 	privilegeProto.RegisterPrivilegeServiceServer(grpcServer, handle)
+	handler.RegisterAdminServiceServer(grpcServer, handle)
+	healthpb.RegisterHealthServer(grpcServer, checker.Server())
+	reflection.Register(grpcServer)
+
+	// run the server until it fails or the process is asked to stop
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- grpcServer.Serve(lis)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
 
-	// run the server
-	if err := grpcServer.Serve(lis); err != nil {
-		zapLog.Fatal(fmt.Sprintf("Failed to serve with err %v", err))
+	select {
+	case sig := <-signals:
+		zapLog.Info(fmt.Sprintf("Recieved %v, shutting down", sig))
+	case err := <-serveErr:
+		zapLog.Error(fmt.Sprintf("Failed to serve with err %v", err))
+	}
+
+	shutdown(zapLog, &shutdownTargets{
+		checker:       checker,
+		grpcServer:    grpcServer,
+		httpServer:    httpServer,
+		healthServer:  healthServer,
+		metricsServer: metricsServer,
+		stopTracing:   stopTracing,
+		cancel:        cancel,
+		workers:       &workers,
+		client:        client,
+		users:         users,
+		sink:          sink,
+		drainDelay:    time.Duration(cfg.Service.DrainDelay),
+		drainTimeout:  time.Duration(cfg.Service.DrainTimeout),
+	})
+}
+
+// newLimiter - the limiter enforcing the configured rate and concurrency
+// limits.
+func newLimiter(cfg *config.Config) (*ratelimit.Limiter, error) {
+	rates, err := ratelimit.ParseRates(cfg.Limits.Rates)
+	if err != nil {
+		return nil, err
+	}
+	concurrency, err := ratelimit.ParseConcurrency(cfg.Limits.Concurrency)
+	if err != nil {
+		return nil, err
 	}
+	return ratelimit.NewLimiter(rates, concurrency), nil
 }
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	health "github.com/softcorp-io/hqs-privileges-service/health"
+	outbox "github.com/softcorp-io/hqs-privileges-service/outbox"
+	userservice "github.com/softcorp-io/hqs-privileges-service/userservice"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// disconnectTimeout - how long closing the Mongo connection may take.
+const disconnectTimeout = 5 * time.Second
+
+// shutdownTargets - everything Run started that must be stopped.
+type shutdownTargets struct {
+	checker       *health.Checker
+	grpcServer    *grpc.Server
+	httpServer    *http.Server
+	healthServer  *grpc.Server
+	metricsServer *http.Server
+	stopTracing   func(ctx context.Context) error
+	cancel        context.CancelFunc
+	workers       *sync.WaitGroup
+	client        *mongo.Client
+	users         *userservice.Client
+	sink          outbox.Sink
+	drainDelay    time.Duration
+	drainTimeout  time.Duration
+}
+
+// shutdown - reports the service as not ready and keeps serving for the drain
+// delay, so load balancers stop sending requests first. It then ends health
+// watches, drains the grpc and http servers within the drain timeout, stops
+// background workers and closes the user service and Mongo connections, in
+// that order so nothing in flight loses its dependencies. Remaining spans are
+// flushed last and metrics are served until the very end.
+func shutdown(zapLog *zap.Logger, t *shutdownTargets) {
+	t.checker.Drain()
+	if t.drainDelay > 0 {
+		zapLog.Info(fmt.Sprintf("Reported not ready, stopping in %v", t.drainDelay))
+		time.Sleep(t.drainDelay)
+	}
+	// open watch streams would keep the grpc server from stopping
+	t.checker.Shutdown()
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), t.drainTimeout)
+	defer cancelDrain()
+
+	drained := make(chan struct{})
+	go func() {
+		t.grpcServer.GracefulStop()
+		close(drained)
+	}()
+
+	if t.httpServer != nil {
+		if err := t.httpServer.Shutdown(drainCtx); err != nil {
+			zapLog.Error(fmt.Sprintf("Could not drain http gateway with err %v", err))
+			t.httpServer.Close()
+		}
+	}
+
+	select {
+	case <-drained:
+		zapLog.Info("Drained in-flight requests")
+	case <-drainCtx.Done():
+		zapLog.Warn(fmt.Sprintf("Requests still in flight after %v, stopping anyway", t.drainTimeout))
+		t.grpcServer.Stop()
+		<-drained
+	}
+
+	if t.healthServer != nil {
+		t.healthServer.Stop()
+	}
+
+	t.cancel()
+	t.workers.Wait()
+	t.users.Close()
+	if t.sink != nil {
+		if err := t.sink.Close(); err != nil {
+			zapLog.Error(fmt.Sprintf("Could not close outbox sink with err %v", err))
+		}
+	}
+
+	disconnectCtx, cancelDisconnect := context.WithTimeout(context.Background(), disconnectTimeout)
+	defer cancelDisconnect()
+	if err := t.client.Disconnect(disconnectCtx); err != nil {
+		zapLog.Error(fmt.Sprintf("Could not disconnect from mongo with err %v", err))
+	}
+
+	if err := t.stopTracing(disconnectCtx); err != nil {
+		zapLog.Error(fmt.Sprintf("Could not flush traces with err %v", err))
+	}
+
+	if t.metricsServer != nil {
+		t.metricsServer.Close()
+	}
+
+	zapLog.Info("Service stopped")
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	certs "github.com/softcorp-io/hqs-privileges-service/certs"
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// loadCertificates - loads the certificates the service serves with and the
+// ones used to call the user service, and keeps reloading them until ctx is
+// done. A store is nil when tls is not configured for it.
+func loadCertificates(ctx context.Context, zapLog *zap.Logger, cfg *config.Config, workers *sync.WaitGroup) (*certs.Store, *certs.Store, error) {
+	var serverCerts, userServiceCerts *certs.Store
+	var err error
+
+	serverTLS := cfg.Service.TLS
+	if serverTLS.Enabled() {
+		serverCerts, err = certs.NewStore(serverTLS.CertFile, serverTLS.KeyFile, serverTLS.ClientCAFile, zapLog)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	userServiceTLS := cfg.UserService.TLS
+	if userServiceTLS.Enabled {
+		userServiceCerts, err = certs.NewStore(userServiceTLS.CertFile, userServiceTLS.KeyFile, userServiceTLS.CAFile, zapLog)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, store := range []*certs.Store{serverCerts, userServiceCerts} {
+		if store == nil {
+			continue
+		}
+		store := store
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			store.Run(ctx, time.Duration(serverTLS.ReloadInterval))
+		}()
+	}
+
+	return serverCerts, userServiceCerts, nil
+}
+
+// tlsOptions - the interceptors enforcing the allowed client certificate SANs
+// and the server option serving tls, all empty without tls.
+func tlsOptions(cfg *config.Config, serverCerts *certs.Store) ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor, []grpc.ServerOption, error) {
+	if serverCerts == nil {
+		return nil, nil, nil, nil
+	}
+	options := []grpc.ServerOption{grpc.Creds(credentials.NewTLS(certs.ServerConfig(serverCerts)))}
+	if len(cfg.Service.TLS.AllowedSANs) == 0 {
+		return nil, nil, options, nil
+	}
+
+	policy, err := certs.ParseSANPolicy(cfg.Service.TLS.AllowedSANs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return []grpc.UnaryServerInterceptor{policy.UnaryInterceptor}, []grpc.StreamServerInterceptor{policy.StreamInterceptor}, options, nil
+}
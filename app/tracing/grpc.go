@@ -0,0 +1,140 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// metadataCarrier - lets propagators read and write grpc metadata.
+type metadataCarrier metadata.MD
+
+// Get - the first value of key.
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Set - replaces the values of key with value.
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys - every key in the metadata.
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// methodAttributes - the rpc attributes of fullMethod.
+func methodAttributes(fullMethod string) []attribute.KeyValue {
+	service, method := strings.TrimPrefix(fullMethod, "/"), ""
+	if i := strings.LastIndex(service, "/"); i >= 0 {
+		service, method = service[:i], service[i+1:]
+	}
+	return []attribute.KeyValue{
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.service", service),
+		attribute.String("rpc.method", method),
+	}
+}
+
+// startServer - starts the span of a call to fullMethod, continuing the trace
+// in the incoming metadata of ctx.
+func startServer(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md.Copy()))
+	return tracer().Start(ctx, strings.TrimPrefix(fullMethod, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(methodAttributes(fullMethod)...),
+	)
+}
+
+// end - records the grpc code of err on span and ends it.
+func end(span trace.Span, err error, failed func(grpcCodes.Code) bool) {
+	code := status.Code(err)
+	span.SetAttributes(attribute.Int64("rpc.grpc.status_code", int64(code)))
+	if failed(code) {
+		span.SetStatus(codes.Error, status.Convert(err).Message())
+	}
+	span.End()
+}
+
+// serverFailed - codes the service is to blame for, the rest are the caller's.
+func serverFailed(code grpcCodes.Code) bool {
+	switch code {
+	case grpcCodes.Unknown, grpcCodes.DeadlineExceeded, grpcCodes.Unimplemented, grpcCodes.Internal, grpcCodes.Unavailable, grpcCodes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// clientFailed - every code but OK.
+func clientFailed(code grpcCodes.Code) bool {
+	return code != grpcCodes.OK
+}
+
+// UnaryServerInterceptor - runs every call in a span continuing the trace of
+// the caller. It should run outermost so everything else is inside the span.
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, span := startServer(ctx, info.FullMethod)
+	resp, err := handler(ctx, req)
+	end(span, err, serverFailed)
+	return resp, err
+}
+
+// StreamServerInterceptor - the streaming counterpart of
+// UnaryServerInterceptor.
+func StreamServerInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, span := startServer(stream.Context(), info.FullMethod)
+	err := handler(srv, &tracedStream{stream, ctx})
+	end(span, err, serverFailed)
+	return err
+}
+
+// tracedStream - a server stream carrying the span of the call.
+type tracedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context - the context with the span of the call.
+func (s *tracedStream) Context() context.Context {
+	return s.ctx
+}
+
+// UnaryClientInterceptor - runs every outgoing call in a span and passes the
+// trace on in the outgoing metadata.
+func UnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, span := tracer().Start(ctx, strings.TrimPrefix(method, "/"),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(methodAttributes(method)...),
+	)
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	end(span, err, clientFailed)
+	return err
+}
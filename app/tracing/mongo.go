@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// commandKey - identifies a command until it finishes. Request ids are only
+// unique per connection.
+type commandKey struct {
+	connection string
+	request    int64
+}
+
+// CommandMonitor - a mongo command monitor running every command in a span
+// below the operation that sent it. Commands are not recorded, they may hold
+// user data.
+func CommandMonitor() *event.CommandMonitor {
+	var spans sync.Map
+
+	finish := func(connection string, request int64, err string) {
+		value, ok := spans.LoadAndDelete(commandKey{connection, request})
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		if err != "" {
+			span.SetStatus(codes.Error, err)
+		}
+		span.End()
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			_, span := tracer().Start(ctx, "mongo."+e.CommandName,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("db.system", "mongodb"),
+					attribute.String("db.name", e.DatabaseName),
+					attribute.String("db.operation", e.CommandName),
+				),
+			)
+			spans.Store(commandKey{e.ConnectionID, e.RequestID}, span)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finish(e.ConnectionID, e.RequestID, "")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finish(e.ConnectionID, e.RequestID, e.Failure)
+		},
+	}
+}
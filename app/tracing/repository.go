@@ -0,0 +1,30 @@
+package tracing
+
+import (
+	"context"
+
+	repository "github.com/softcorp-io/hqs-privileges-service/repository"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// RepositoryHook - a repository.Hook running every operation in a span, so
+// the mongo commands it sends are grouped below it.
+func RepositoryHook(ctx context.Context, operation string) (context.Context, func(err error)) {
+	ctx, span := tracer().Start(ctx, "repository."+operation)
+	return ctx, func(err error) {
+		if err != nil {
+			// errors the caller caused are part of normal operation
+			if repoErr := repository.AsError(err); repoErr != nil {
+				span.SetAttributes(attribute.String("error.kind", repoErr.Kind.String()))
+				if repoErr.Kind != repository.KindInternal && repoErr.Kind != repository.KindUnavailable {
+					span.End()
+					return
+				}
+			}
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"os"
+
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation - name of the tracer every span of the service is started
+// with.
+const instrumentation = "github.com/softcorp-io/hqs-privileges-service"
+
+// tracer - the tracer of the service, from whichever provider is installed.
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Setup - installs the tracer provider and propagators described by cfg. Trace
+// context is propagated even when nothing is exported, so traces passing
+// through the service stay whole. The returned func flushes and stops the
+// exporter.
+func Setup(ctx context.Context, cfg config.Tracing) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case config.TracingOTLP:
+		options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint)}
+		if cfg.OTLPInsecure {
+			options = append(options, otlptracegrpc.WithInsecure())
+		}
+		otlp, err := otlptracegrpc.New(ctx, options...)
+		if err != nil {
+			return nil, err
+		}
+		exporter = otlp
+	case config.TracingStdout:
+		exporter = NewWriterExporter(os.Stdout)
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes("", attribute.String("service.name", cfg.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
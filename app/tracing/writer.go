@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// WriterExporter - writes every span as a line of json, for local debugging.
+type WriterExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterExporter - returns an exporter writing spans to w.
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+// writtenSpan - how a span is written.
+type writtenSpan struct {
+	Name       string                 `json:"name"`
+	Kind       string                 `json:"kind"`
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	ParentID   string                 `json:"parent_id,omitempty"`
+	Start      time.Time              `json:"start"`
+	Duration   string                 `json:"duration"`
+	Status     string                 `json:"status"`
+	Error      string                 `json:"error,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// ExportSpans - writes spans to the writer.
+func (e *WriterExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	encoder := json.NewEncoder(e.w)
+	for _, span := range spans {
+		written := writtenSpan{
+			Name:     span.Name(),
+			Kind:     span.SpanKind().String(),
+			TraceID:  span.SpanContext().TraceID().String(),
+			SpanID:   span.SpanContext().SpanID().String(),
+			Start:    span.StartTime(),
+			Duration: span.EndTime().Sub(span.StartTime()).String(),
+			Status:   span.Status().Code.String(),
+			Error:    span.Status().Description,
+		}
+		if span.Parent().IsValid() {
+			written.ParentID = span.Parent().SpanID().String()
+		}
+		for _, kv := range span.Attributes() {
+			if written.Attributes == nil {
+				written.Attributes = map[string]interface{}{}
+			}
+			written.Attributes[string(kv.Key)] = kv.Value.AsInterface()
+		}
+		if err := encoder.Encode(&written); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown - nothing to release, the writer belongs to the caller.
+func (e *WriterExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
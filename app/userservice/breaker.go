@@ -0,0 +1,100 @@
+package userservice
+
+import (
+	"sync"
+	"time"
+)
+
+// Breaker states.
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half_open"
+)
+
+// breaker - circuit breaker that opens after threshold consecutive failures
+// and lets a single trial call through once cooldown has passed. Only the
+// outcome of the trial call closes or reopens it, calls that started before it
+// opened are ignored.
+type breaker struct {
+	threshold int64
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int64
+	openedAt time.Time
+	trial    bool
+	opened   int64
+}
+
+func newBreaker(threshold int64, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, state: StateClosed}
+}
+
+// allow - reports whether a call may go through and whether it is the trial
+// call.
+func (b *breaker) allow() (bool, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false, false
+		}
+		b.state = StateHalfOpen
+		b.trial = true
+		return true, true
+	case StateHalfOpen:
+		if b.trial {
+			return false, false
+		}
+		b.trial = true
+		return true, true
+	}
+	return true, false
+}
+
+// abandon - lets another trial call through when the trial call ended without
+// telling whether the user service is healthy.
+func (b *breaker) abandon(trial bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if trial {
+		b.trial = false
+	}
+}
+
+// record - updates the breaker with the outcome of a call.
+func (b *breaker) record(trial bool, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if trial {
+		b.trial = false
+	} else if b.state != StateClosed {
+		return
+	}
+	if !failed {
+		b.state = StateClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		if b.state != StateOpen {
+			b.opened++
+		}
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *breaker) snapshot() (string, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, b.opened
+}
@@ -0,0 +1,114 @@
+package userservice
+
+import (
+	"testing"
+	"time"
+)
+
+// step - an action on a breaker and the state expected afterwards.
+type step struct {
+	action string
+	// for allow, the expected result, for record and abandon, whether the
+	// call was the trial call
+	trial   bool
+	allowed bool
+	failed  bool
+	state   string
+}
+
+func TestBreaker(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"opens after threshold failures", []step{
+			{action: "record", failed: true, state: StateClosed},
+			{action: "record", failed: true, state: StateOpen},
+			{action: "allow", allowed: false, state: StateOpen},
+		}},
+		{"success resets the failures", []step{
+			{action: "record", failed: true, state: StateClosed},
+			{action: "record", failed: false, state: StateClosed},
+			{action: "record", failed: true, state: StateClosed},
+		}},
+		{"a single trial once cooled down", []step{
+			{action: "open", state: StateOpen},
+			{action: "cool", state: StateOpen},
+			{action: "allow", allowed: true, trial: true, state: StateHalfOpen},
+			{action: "allow", allowed: false, state: StateHalfOpen},
+		}},
+		{"successful trial closes", []step{
+			{action: "open", state: StateOpen},
+			{action: "cool", state: StateOpen},
+			{action: "allow", allowed: true, trial: true, state: StateHalfOpen},
+			{action: "record", trial: true, failed: false, state: StateClosed},
+			{action: "allow", allowed: true, trial: false, state: StateClosed},
+		}},
+		{"failed trial reopens", []step{
+			{action: "open", state: StateOpen},
+			{action: "cool", state: StateOpen},
+			{action: "allow", allowed: true, trial: true, state: StateHalfOpen},
+			{action: "record", trial: true, failed: true, state: StateOpen},
+			{action: "allow", allowed: false, state: StateOpen},
+		}},
+		{"late calls do not end the trial", []step{
+			{action: "open", state: StateOpen},
+			{action: "cool", state: StateOpen},
+			{action: "allow", allowed: true, trial: true, state: StateHalfOpen},
+			{action: "record", trial: false, failed: false, state: StateHalfOpen},
+			{action: "record", trial: false, failed: true, state: StateHalfOpen},
+			{action: "allow", allowed: false, state: StateHalfOpen},
+		}},
+		{"late calls do not reopen", []step{
+			{action: "open", state: StateOpen},
+			{action: "record", trial: false, failed: false, state: StateOpen},
+			{action: "cool", state: StateOpen},
+			{action: "record", trial: false, failed: true, state: StateOpen},
+			{action: "allow", allowed: true, trial: true, state: StateHalfOpen},
+		}},
+		{"abandoned trial lets another through", []step{
+			{action: "open", state: StateOpen},
+			{action: "cool", state: StateOpen},
+			{action: "allow", allowed: true, trial: true, state: StateHalfOpen},
+			{action: "abandon", trial: true, state: StateHalfOpen},
+			{action: "allow", allowed: true, trial: true, state: StateHalfOpen},
+		}},
+		{"abandoned late call keeps the trial", []step{
+			{action: "open", state: StateOpen},
+			{action: "cool", state: StateOpen},
+			{action: "allow", allowed: true, trial: true, state: StateHalfOpen},
+			{action: "abandon", trial: false, state: StateHalfOpen},
+			{action: "allow", allowed: false, state: StateHalfOpen},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newBreaker(2, time.Minute)
+			for i, s := range test.steps {
+				switch s.action {
+				case "allow":
+					allowed, trial := b.allow()
+					if allowed != s.allowed || trial != s.trial {
+						t.Fatalf("step %d: allow() = %v, %v, expected %v, %v", i, allowed, trial, s.allowed, s.trial)
+					}
+				case "record":
+					b.record(s.trial, s.failed)
+				case "abandon":
+					b.abandon(s.trial)
+				case "open":
+					b.record(false, true)
+					b.record(false, true)
+				case "cool":
+					b.mu.Lock()
+					b.openedAt = time.Now().Add(-b.cooldown)
+					b.mu.Unlock()
+				}
+
+				if state, _ := b.snapshot(); state != s.state {
+					t.Fatalf("step %d: state %s, expected %s", i, state, s.state)
+				}
+			}
+		})
+	}
+}
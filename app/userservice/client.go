@@ -0,0 +1,188 @@
+package userservice
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	certs "github.com/softcorp-io/hqs-privileges-service/certs"
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+	tracing "github.com/softcorp-io/hqs-privileges-service/tracing"
+	userProto "github.com/softcorp-io/hqs_proto/go_hqs/hqs_user_service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// Client - long-lived client of the user service. Calls share one connection,
+// are bounded by the deadline of the caller, retried with jitter when the user
+// service is unavailable and cut short by a circuit breaker when it keeps
+// failing.
+type Client struct {
+	conn    *grpc.ClientConn
+	users   userProto.UserServiceClient
+	cfg     config.UserService
+	breaker *breaker
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// Stats - outcomes and latency of calls to the user service.
+type Stats struct {
+	Calls        int64         `json:"calls"`
+	Failures     int64         `json:"failures"`
+	Retries      int64         `json:"retries"`
+	Rejected     int64         `json:"rejected"`
+	TotalLatency time.Duration `json:"total_latency"`
+	MaxLatency   time.Duration `json:"max_latency"`
+	BreakerState string        `json:"breaker_state"`
+	BreakerOpens int64         `json:"breaker_opens"`
+}
+
+// NewClient - returns a client of the user service in cfg. The connection is
+// made lazily and kept open until Close. With a store the user service is
+// called over tls, verified against and presenting the certificates in it.
+// Every call passes the trace of its context on.
+func NewClient(cfg config.UserService, store *certs.Store) (*Client, error) {
+	transport := grpc.WithInsecure()
+	if store != nil {
+		serverName := cfg.TLS.ServerName
+		if serverName == "" {
+			serverName = cfg.Host
+		}
+		transport = grpc.WithTransportCredentials(credentials.NewTLS(certs.ClientConfig(store, serverName)))
+	}
+
+	conn, err := grpc.Dial(cfg.Address(), transport, grpc.WithUnaryInterceptor(tracing.UnaryClientInterceptor))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		conn:    conn,
+		users:   userProto.NewUserServiceClient(conn),
+		cfg:     cfg,
+		breaker: newBreaker(cfg.BreakerThreshold, time.Duration(cfg.BreakerCooldown)),
+	}, nil
+}
+
+// Close - closes the connection to the user service.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// ValidateToken - asks the user service who token belongs to and what it may
+// do.
+func (c *Client) ValidateToken(ctx context.Context, token string) (*userProto.Token, error) {
+	var result *userProto.Token
+	err := c.call(ctx, func(callCtx context.Context) error {
+		var err error
+		result, err = c.users.ValidateToken(callCtx, &userProto.Token{Token: token})
+		return err
+	})
+	return result, err
+}
+
+// Ping - checks that the user service answers, bypassing the breaker and
+// retries so health checks see the service as it is.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.users.Ping(ctx, &userProto.Request{})
+	return err
+}
+
+// Stats - a snapshot of the call statistics.
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	stats := c.stats
+	c.mu.Unlock()
+
+	stats.BreakerState, stats.BreakerOpens = c.breaker.snapshot()
+	return stats
+}
+
+// call - runs fn with a per attempt timeout derived from ctx, retrying
+// transient failures.
+func (c *Client) call(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := int64(0); ; attempt++ {
+		allowed, trial := c.breaker.allow()
+		if !allowed {
+			c.count(func(s *Stats) { s.Rejected++ })
+			return status.Error(codes.Unavailable, "User service circuit breaker is open")
+		}
+
+		start := time.Now()
+		callCtx, cancel := context.WithTimeout(ctx, time.Duration(c.cfg.Timeout))
+		err = fn(callCtx)
+		cancel()
+		latency := time.Since(start)
+
+		// a caller giving up says nothing about the user service, only the
+		// attempt timeout set here does
+		abandoned := ctx.Err() != nil
+		failed := !abandoned && isFailure(err)
+		if abandoned {
+			c.breaker.abandon(trial)
+		} else {
+			c.breaker.record(trial, failed)
+		}
+		c.count(func(s *Stats) {
+			s.Calls++
+			s.TotalLatency += latency
+			if latency > s.MaxLatency {
+				s.MaxLatency = latency
+			}
+			if failed {
+				s.Failures++
+			}
+		})
+
+		if abandoned || !isRetryable(err) || attempt >= c.cfg.Retries {
+			return err
+		}
+
+		c.count(func(s *Stats) { s.Retries++ })
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(c.backoff(attempt)):
+		}
+	}
+}
+
+// backoff - exponential backoff with full jitter.
+func (c *Client) backoff(attempt int64) time.Duration {
+	max := time.Duration(c.cfg.RetryBackoff) << uint(attempt)
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+func (c *Client) count(fn func(s *Stats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(&c.stats)
+}
+
+// isFailure - reports whether err means the user service is unhealthy, as
+// opposed to it rejecting the token.
+func isFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal:
+		return true
+	}
+	return false
+}
+
+// isRetryable - reports whether the call may succeed when tried again.
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
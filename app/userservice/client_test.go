@@ -0,0 +1,70 @@
+package userservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	config "github.com/softcorp-io/hqs-privileges-service/config"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClientCall(t *testing.T) {
+	// waits for the attempt to end, like a user service that does not answer
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	reject := func(ctx context.Context) error {
+		return status.Error(codes.Unauthenticated, "rejected")
+	}
+
+	tests := []struct {
+		name string
+		fn   func(ctx context.Context) error
+		// deadline set by the caller, none when zero
+		deadline time.Duration
+		cancel   bool
+		code     codes.Code
+		failures int64
+		state    string
+	}{
+		{"attempt timeout", hang, 0, false, codes.DeadlineExceeded, 1, StateOpen},
+		{"caller deadline", hang, 20 * time.Millisecond, false, codes.DeadlineExceeded, 0, StateClosed},
+		{"caller cancelled", hang, 0, true, codes.Canceled, 0, StateClosed},
+		{"rejected token", reject, 0, false, codes.Unauthenticated, 0, StateClosed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Client{
+				cfg:     config.UserService{Timeout: config.Duration(200 * time.Millisecond)},
+				breaker: newBreaker(1, time.Minute),
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if test.deadline > 0 {
+				ctx, cancel = context.WithTimeout(ctx, test.deadline)
+				defer cancel()
+			}
+			if test.cancel {
+				time.AfterFunc(20*time.Millisecond, cancel)
+			}
+
+			err := c.call(ctx, test.fn)
+			if status.Code(err) != test.code {
+				t.Fatalf("expected %v, got %v", test.code, err)
+			}
+
+			stats := c.Stats()
+			if stats.Calls != 1 || stats.Failures != test.failures {
+				t.Fatalf("expected 1 call and %d failures, got %+v", test.failures, stats)
+			}
+			if stats.BreakerState != test.state {
+				t.Fatalf("expected breaker %s, got %s", test.state, stats.BreakerState)
+			}
+		})
+	}
+}